@@ -0,0 +1,188 @@
+// Command analyze crawls one or more URLs with the same fetcher and crawler
+// stack as the web server and prints the results.
+//
+// URLs are taken from the arguments or, without arguments, one per line from
+// stdin. The exit code is 0 when every URL was crawled without broken links,
+// images or resources, 1 when any of them were found, 2 on usage errors and 3
+// when a URL could not be crawled.
+//
+// In gate mode, enabled by -rules, -junit or -sarif, the crawls are checked
+// against rules and thresholds instead and the exit code is 1 when a rule of
+// level error was violated.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/gate"
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitUsage    = 2
+	exitFailed   = 3
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze [flags] [url ...]\n\nURLs are read from stdin when none are given.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	var (
+		format   = flags.String("format", formatTable, "output format: table, json or ndjson")
+		depth    = flags.Int("depth", 0, "levels of pages to crawl (default from CRAWLER_CRAWL_DEPTH)")
+		conc     = flags.Int("concurrency", 0, "concurrent requests shared by all URLs (default from CRAWLER_CONCURRENCY_LIMIT)")
+		ping     = flags.String("ping", "", "how links are requested: head or get (default from CRAWLER_PING_STRATEGY)")
+		timeout  = flags.Duration("timeout", 2*time.Minute, "timeout of the crawl of a single URL")
+		progress = flags.Bool("progress", false, "print crawl progress to stderr")
+		verbose  = flags.Bool("v", false, "print logs to stderr")
+		rules    = flags.String("rules", "", "JSON file with the rules of gate mode (default rules when empty)")
+		junit    = flags.String("junit", "", "write the gate checks as JUnit XML to this file")
+		sarif    = flags.String("sarif", "", "write the gate violations as SARIF to this file")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "unsupported format: %s\n", *format)
+		return exitUsage
+	}
+
+	br := &crawler.BatchRequest{
+		URLs: flags.Args(),
+		Options: crawler.CrawlRequestOptions{
+			Depth:        *depth,
+			Concurrency:  *conc,
+			PingStrategy: *ping,
+		},
+	}
+
+	if len(br.URLs) == 0 {
+		urls, err := crawler.ParseURLList(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "could not read urls: %s\n", err)
+			return exitUsage
+		}
+		br.URLs = urls
+	}
+
+	if err := br.ValidateUnlimited(); err != nil {
+		fmt.Fprintln(stderr, err)
+		flags.Usage()
+		return exitUsage
+	}
+
+	gateMode := *rules != "" || *junit != "" || *sarif != ""
+	gateConfig := gate.DefaultConfig()
+	if *rules != "" {
+		cfg, err := gate.LoadConfigFile(*rules)
+		if err != nil {
+			fmt.Fprintf(stderr, "could not load rules: %s\n", err)
+			return exitUsage
+		}
+		gateConfig = cfg
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	l := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	config := util.LoadCrawlerConfigFromEnv()
+	hc := &http.Client{Timeout: config.CrawlerTimeout}
+	f := fetcher.NewFetcherFromConfig(hc, l, config)
+	c := crawler.NewCrawlerFromConfig(f, hc, l, config)
+	batch := crawler.NewBatchRunnerFromConfig(c, l, config, crawler.WithBatchTimeout(*timeout))
+
+	opts := br.CrawlOptions()
+	if *progress {
+		opts = append(opts, crawler.WithObserver(newProgressPrinter(stderr)))
+	}
+
+	report := batch.Run(ctx, br.URLs, opts...)
+
+	if err := writeReport(stdout, *format, report); err != nil {
+		fmt.Fprintf(stderr, "could not write report: %s\n", err)
+		return exitFailed
+	}
+
+	if !gateMode {
+		s := report.Summary
+		return exitCode(report, s.BrokenLinks > 0 || s.BrokenImages > 0 || s.BrokenResources > 0)
+	}
+
+	result := gate.Evaluate(gateConfig, report)
+	if err := writeGateReports(result, *junit, *sarif); err != nil {
+		fmt.Fprintf(stderr, "could not write gate report: %s\n", err)
+		return exitFailed
+	}
+	for _, c := range result.Violations() {
+		fmt.Fprintf(stderr, "%s [%s] %s\n", c.Level, c.Rule, c.Message)
+	}
+
+	return exitCode(report, result.Failed())
+}
+
+// exitCode reports failed URLs before failed checks
+func exitCode(report *crawler.BatchReport, failed bool) int {
+	switch {
+	case report.Failed > 0:
+		return exitFailed
+	case failed:
+		return exitFindings
+	default:
+		return exitOK
+	}
+}
+
+// progressPrinter prints fetched and failed pages and broken links. The
+// crawls of a batch report concurrently, so lines are written one at a time.
+type progressPrinter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newProgressPrinter(w io.Writer) *progressPrinter {
+	return &progressPrinter{w: w}
+}
+
+func (p *progressPrinter) OnEvent(e crawler.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch e.Type {
+	case crawler.EventPageFetched:
+		fmt.Fprintf(p.w, "fetched %s (depth %d)\n", e.PageURL, e.Depth)
+	case crawler.EventPageFailed:
+		fmt.Fprintf(p.w, "failed  %s: %s\n", e.PageURL, e.Error)
+	case crawler.EventLinkBroken:
+		fmt.Fprintf(p.w, "broken  %s on %s: %s\n", e.Link.URL, e.PageURL, linkProblem(e.Link.URLCheck))
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupFakeWebsite() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthy", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Healthy</title></head><body><a href="/about">About</a></body></html>`)
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>About</title></head></html>`)
+	})
+	mux.HandleFunc("/unstyled", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Unstyled</title><link rel="stylesheet" href="/missing.css"></head></html>`)
+	})
+	mux.HandleFunc("/gallery", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Gallery</title></head><body><img src="/missing.jpg" alt="Sofa"></body></html>`)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Broken</title></head><body><a href="/about">About</a><a href="/missing">Missing</a></body></html>`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func runAnalyze(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Table(t *testing.T) {
+	server := setupFakeWebsite()
+	defer server.Close()
+
+	code, stdout, _ := runAnalyze("", server.URL+"/healthy")
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout, "URL")
+	assert.Contains(t, stdout, server.URL+"/healthy")
+	assert.Contains(t, stdout, "TOTAL")
+	assert.NotContains(t, stdout, "BROKEN LINK")
+
+	code, stdout, stderr := runAnalyze("", "-progress", server.URL+"/broken")
+
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stdout, "BROKEN LINK")
+	assert.Contains(t, stdout, server.URL+"/missing")
+	assert.Contains(t, stderr, "fetched "+server.URL+"/broken")
+	assert.Contains(t, stderr, "broken  "+server.URL+"/missing")
+
+	code, stdout, _ = runAnalyze("", server.URL+"/unstyled")
+
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stdout, "BROKEN RESOURCE")
+	assert.Contains(t, stdout, server.URL+"/missing.css")
+	assert.NotContains(t, stdout, "BROKEN LINK")
+
+	code, _, _ = runAnalyze("", server.URL+"/gallery")
+	assert.Equal(t, exitFindings, code)
+}
+
+func TestRun_JSON(t *testing.T) {
+	server := setupFakeWebsite()
+	defer server.Close()
+
+	code, stdout, _ := runAnalyze("", "-format", "json", "-depth", "2", server.URL+"/broken", "ftp://example.com")
+
+	assert.Equal(t, exitFailed, code)
+
+	var report crawler.BatchReport
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, crawler.BatchSummary{PagesCrawled: 2, LinksChecked: 2, BrokenLinks: 1}, report.Items[0].Summary)
+}
+
+func TestRun_NDJSONFromStdin(t *testing.T) {
+	server := setupFakeWebsite()
+	defer server.Close()
+
+	code, stdout, _ := runAnalyze(server.URL+"/healthy\n\n"+server.URL+"/broken\n", "-format", "ndjson")
+
+	assert.Equal(t, exitFindings, code)
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 2)
+
+	var item crawler.BatchItem
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &item))
+	assert.Equal(t, server.URL+"/broken", item.URL)
+	assert.Equal(t, 1, item.Summary.BrokenLinks)
+}
+
+func TestRun_ManyURLs(t *testing.T) {
+	server := setupFakeWebsite()
+	defer server.Close()
+
+	t.Setenv("CRAWLER_HOST_RATE_LIMIT", "0")
+
+	// The batch limit of the API does not apply
+	urls := strings.Repeat(server.URL+"/about\n", 101)
+	code, stdout, _ := runAnalyze(urls, "-format", "json")
+
+	assert.Equal(t, exitOK, code)
+
+	var report crawler.BatchReport
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, 101, report.Total)
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runAnalyze("")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "at least one url is required")
+
+	code, _, stderr = runAnalyze("", "-format", "xml", "https://example.com")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "unsupported format")
+
+	code, _, _ = runAnalyze("", "-depth", "9", "https://example.com")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runAnalyze("", "-unknown")
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestRun_Gate(t *testing.T) {
+	server := setupFakeWebsite()
+	defer server.Close()
+
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.json")
+	junit := filepath.Join(dir, "junit.xml")
+	sarif := filepath.Join(dir, "results.sarif")
+
+	assert.NoError(t, os.WriteFile(rules, []byte(`{"rules": [{"rule": "broken-links", "max": 1}, {"rule": "missing-h1", "level": "warning"}]}`), 0o600))
+
+	// One broken link is within the threshold, missing headings only warn
+	code, _, stderr := runAnalyze("", "-rules", rules, "-junit", junit, "-sarif", sarif, server.URL+"/broken")
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr, "warning [missing-h1]")
+
+	report, err := os.ReadFile(junit)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `<testcase name="broken-links" classname="`+server.URL+`/broken">`)
+
+	report, err = os.ReadFile(sarif)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `"ruleId": "missing-h1"`)
+
+	// The default rules fail on any broken link
+	code, _, stderr = runAnalyze("", "-junit", junit, server.URL+"/broken")
+
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stderr, "error [broken-links]")
+
+	// Like the plain exit code the default rules fail on broken images and
+	// resources
+	code, _, stderr = runAnalyze("", "-junit", junit, server.URL+"/gallery")
+
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stderr, "error [broken-images]")
+
+	code, _, stderr = runAnalyze("", "-junit", junit, server.URL+"/unstyled")
+
+	assert.Equal(t, exitFindings, code)
+	assert.Contains(t, stderr, "error [broken-resources]")
+
+	code, _, stderr = runAnalyze("", "-rules", filepath.Join(dir, "missing.json"), server.URL+"/broken")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "could not load rules")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/gate"
+)
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatNDJSON
+}
+
+// writeReport writes the report in the given format. NDJSON has one line
+// per URL, JSON is the whole report.
+func writeReport(w io.Writer, format string, report *crawler.BatchReport) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, item := range report.Items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return writeTable(w, report)
+	}
+}
+
+// writeTable writes a summary row per URL followed by the broken resources,
+// links and images
+func writeTable(w io.Writer, report *crawler.BatchReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "URL\tSTATUS\tPAGES\tLINKS\tBROKEN\tSKIPPED\tDURATION\tERROR")
+	for _, item := range report.Items {
+		s := item.Summary
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n", item.URL, item.Status, s.PagesCrawled, s.LinksChecked, s.BrokenLinks, s.SkippedLinks, round(item.Duration), item.Error)
+	}
+	s := report.Summary
+	fmt.Fprintf(tw, "TOTAL\t%d/%d ok\t%d\t%d\t%d\t%d\t%s\t\n", report.Succeeded, report.Total, s.PagesCrawled, s.LinksChecked, s.BrokenLinks, s.SkippedLinks, round(report.Duration))
+
+	if s.BrokenResources > 0 {
+		fmt.Fprintln(tw, "\nPAGE\tBROKEN RESOURCE\tKIND\tPROBLEM")
+		for _, item := range report.Items {
+			if item.Result == nil {
+				continue
+			}
+			for _, page := range item.Result.Pages() {
+				for _, rc := range page.BrokenResources() {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", page.URL, rc.URL, rc.Resource.Kind, linkProblem(rc.URLCheck))
+				}
+			}
+		}
+	}
+
+	if s.BrokenLinks > 0 {
+		fmt.Fprintln(tw, "\nPAGE\tBROKEN LINK\tREGION\tPROBLEM")
+		for _, item := range report.Items {
+			if item.Result == nil {
+				continue
+			}
+			for _, page := range item.Result.Pages() {
+				for _, lc := range page.BrokenLinks() {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", page.URL, lc.URL, lc.Anchor.Region, linkProblem(lc.URLCheck))
+				}
+			}
+		}
+	}
+
+	if s.BrokenImages > 0 {
+		fmt.Fprintln(tw, "\nPAGE\tBROKEN IMAGE\tPROBLEM")
+		for _, item := range report.Items {
+			if item.Result == nil {
+				continue
+			}
+			for _, page := range item.Result.Pages() {
+				for _, ic := range page.BrokenImages() {
+					for _, uc := range ic.BrokenURLs() {
+						fmt.Fprintf(tw, "%s\t%s\t%s\n", page.URL, uc.URL, linkProblem(uc))
+					}
+				}
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+// linkProblem describes why a link, image or resource is broken
+func linkProblem(lc crawler.URLCheck) string {
+	if lc.StatusCode != 0 {
+		return fmt.Sprintf("%s %d", lc.Method, lc.StatusCode)
+	}
+
+	return fmt.Sprintf("%s: %s", lc.ErrorClass, lc.Error)
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+// writeGateReports writes the JUnit and SARIF files that were asked for
+func writeGateReports(result *gate.Result, junitPath, sarifPath string) error {
+	if junitPath != "" {
+		if err := writeFile(junitPath, func(w io.Writer) error { return gate.WriteJUnit(w, result) }); err != nil {
+			return err
+		}
+	}
+
+	if sarifPath != "" {
+		if err := writeFile(sarifPath, func(w io.Writer) error { return gate.WriteSARIF(w, result) }); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
 	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/jobs"
 	"github.com/rewebcan/url-fetcher-home24/internal/util"
 )
 
@@ -22,12 +23,29 @@ func main() {
 	hc := &http.Client{Timeout: config.CrawlerTimeout}
 	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
 	l := slog.New(jsonHandler)
-	f := fetcher.NewFetcher(hc, l, config.BodySizeLimit)
-	c := crawler.NewCrawler(f, l, crawler.WithConcurrencyLimit(10))
+	f := fetcher.NewFetcherFromConfig(hc, l, config)
+	c := crawler.NewCrawlerFromConfig(f, hc, l, config)
+	batch := crawler.NewBatchRunnerFromConfig(c, l, config)
 
-	crawlCtrl := crawler.NewCrawlController(f, c, l)
+	crawlCtrl := crawler.NewCrawlController(f, c, l, crawler.WithBatchRunner(batch))
 
 	app.HandleFunc("/", crawlCtrl.CrawlHandler)
+	app.HandleFunc("/batch", crawlCtrl.BatchHandler)
+	app.HandleFunc("/api/v1/analyze", crawlCtrl.AnalyzeHandler)
+	app.HandleFunc("/api/v1/batch", crawlCtrl.BatchAnalyzeHandler)
+
+	jobManager := jobs.NewManager(c, l,
+		jobs.WithWorkers(config.JobWorkers),
+		jobs.WithQueueSize(config.JobQueueSize),
+		jobs.WithJobTimeout(config.JobTimeout),
+	)
+	jobCtrl := jobs.NewJobController(jobManager, l)
+
+	app.HandleFunc("POST /api/v1/jobs", jobCtrl.SubmitHandler)
+	app.HandleFunc("GET /api/v1/jobs/{id}", jobCtrl.GetHandler)
+	app.HandleFunc("GET /api/v1/jobs/{id}/events", jobCtrl.EventsHandler)
+	app.HandleFunc("DELETE /api/v1/jobs/{id}", jobCtrl.CancelHandler)
+	app.HandleFunc("GET /jobs/{id}", crawlCtrl.ReportHandler(jobCtrl.Result))
 
 	fmt.Printf("Server is up and running at localhost:8080...\n")
 
@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/jobs"
+)
+
+func TestAnalyzeHandler_E2E(t *testing.T) {
+	fakeServer := setupFakeWebsite()
+	defer fakeServer.Close()
+
+	app := setupWebApp()
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+		checkResponse  func(t *testing.T, body []byte)
+	}{
+		{
+			name:           "valid URL with successful crawl",
+			method:         http.MethodPost,
+			body:           `{"url": "` + fakeServer.URL + `/test-page", "options": {"depth": 2, "ping_strategy": "get"}}`,
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, body []byte) {
+				var result crawler.CrawlResult
+				if err := json.Unmarshal(body, &result); err != nil {
+					t.Fatalf("Expected a JSON crawl result, got: %s", body)
+				}
+				if result.Title != "Test Page" {
+					t.Errorf("Expected title 'Test Page', got: %s", result.Title)
+				}
+				if result.Meta.Description != "Test page for crawling" {
+					t.Errorf("Expected the meta description, got: %+v", result.Meta)
+				}
+				if len(result.LinkChecks) != 3 {
+					t.Fatalf("Expected 3 link checks, got: %d", len(result.LinkChecks))
+				}
+				brokenLink := result.LinkChecks[2]
+				if brokenLink.Status != crawler.LinkStatusBroken || brokenLink.StatusCode != http.StatusNotFound {
+					t.Errorf("Expected the broken link to fail with 404, got: %+v", brokenLink)
+				}
+				if len(result.Children) != 1 || !strings.HasSuffix(result.Children[0].URL, "/internal-link") {
+					t.Errorf("Expected the internal page to be crawled, got: %+v", result.Children)
+				}
+				if forms := result.Children[0].Forms; len(forms) != 1 || forms[0].Kind != fetcher.FormKindLogin {
+					t.Errorf("Expected the login form of the internal page, got: %+v", forms)
+				}
+			},
+		},
+		{
+			name:           "empty URL validation",
+			method:         http.MethodPost,
+			body:           `{"url": ""}`,
+			expectedStatus: http.StatusBadRequest,
+			checkResponse:  expectAPIError("url string can not be empty"),
+		},
+		{
+			name:           "invalid options",
+			method:         http.MethodPost,
+			body:           `{"url": "https://example.com", "options": {"depth": 50}}`,
+			expectedStatus: http.StatusBadRequest,
+			checkResponse:  expectAPIError("depth must be between"),
+		},
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			body:           `{"url":`,
+			expectedStatus: http.StatusBadRequest,
+			checkResponse:  expectAPIError("invalid request body"),
+		},
+		{
+			name:           "upstream bad status",
+			method:         http.MethodPost,
+			body:           `{"url": "` + fakeServer.URL + `/broken-link"}`,
+			expectedStatus: http.StatusBadGateway,
+			checkResponse:  expectAPIError("bad status code"),
+		},
+		{
+			name:           "wrong method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+			checkResponse:  expectAPIError("method not allowed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/analyze", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected JSON content type, got %s", ct)
+			}
+
+			tt.checkResponse(t, w.Body.Bytes())
+		})
+	}
+}
+
+func expectAPIError(message string) func(t *testing.T, body []byte) {
+	return func(t *testing.T, body []byte) {
+		var apiErr crawler.APIError
+		if err := json.Unmarshal(body, &apiErr); err != nil {
+			t.Fatalf("Expected a JSON error, got: %s", body)
+		}
+		if !strings.Contains(apiErr.Error, message) {
+			t.Errorf("Expected error containing '%s', got: %s", message, apiErr.Error)
+		}
+	}
+}
+
+func TestJobHandlers_E2E(t *testing.T) {
+	fakeServer := setupFakeWebsite()
+	defer fakeServer.Close()
+
+	app := setupWebApp()
+
+	// Submit a job
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"url": "`+fakeServer.URL+`/test-page"}`))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var job jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Expected a JSON job, got: %s", w.Body.String())
+	}
+	if w.Header().Get("Location") != "/api/v1/jobs/"+job.ID {
+		t.Errorf("Expected Location of the job, got: %s", w.Header().Get("Location"))
+	}
+
+	// Poll until the job is done
+	deadline := time.Now().Add(10 * time.Second)
+	for !job.Finished() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("Expected a JSON job, got: %s", w.Body.String())
+		}
+	}
+
+	if job.Status != jobs.StatusDone {
+		t.Fatalf("Expected job to be done, got: %+v", job)
+	}
+	if job.Result == nil || job.Result.Title != "Test Page" {
+		t.Errorf("Expected the crawl result, got: %+v", job.Result)
+	}
+	if job.Progress.PagesCrawled != 1 || job.Progress.LinksChecked != 3 {
+		t.Errorf("Expected the final progress, got: %+v", job.Progress)
+	}
+
+	// The report of the job has the sections of the form submission
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	for _, expected := range []string{"Title: Test Page", "The page has no canonical link", "/internal-link"} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("Expected the report to contain %q, got: %s", expected, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "job not found") {
+		t.Errorf("Expected status %d with the error, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Finished jobs can not be canceled
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/jobs/"+job.ID, nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Unknown jobs
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Invalid requests are rejected before they are queued
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"url": "ftp://example.com"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestJobEventsHandler_E2E(t *testing.T) {
+	fakeServer := setupFakeWebsite()
+	defer fakeServer.Close()
+
+	app := setupWebApp()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"url": "`+fakeServer.URL+`/test-page"}`))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	var job jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Expected a JSON job, got: %s", w.Body.String())
+	}
+
+	// The stream ends once the job finished
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/events", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, got: %s", w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{"event: page_fetched\n", "event: link_checked\n", "event: link_broken\n"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in the stream, got: %s", expected, body)
+		}
+	}
+
+	events := strings.Split(strings.TrimSpace(body), "\n\n")
+	last := events[len(events)-1]
+	if !strings.HasPrefix(last, "event: job\ndata: ") || !strings.Contains(last, `"status":"done"`) {
+		t.Errorf("Expected the stream to end with the finished job, got: %s", last)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/unknown/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestBatchAnalyzeHandler_E2E(t *testing.T) {
+	fakeServer := setupFakeWebsite()
+	defer fakeServer.Close()
+
+	app := setupWebApp()
+
+	body := `{"urls": ["` + fakeServer.URL + `/test-page", "ftp://example.com", "` + fakeServer.URL + `/broken-link"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var report crawler.BatchReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a JSON report, got: %s", w.Body.String())
+	}
+
+	if report.Total != 3 || report.Succeeded != 1 || report.Failed != 2 {
+		t.Errorf("Expected one of three URLs to succeed, got: %+v", report)
+	}
+	if report.Items[0].Result == nil || report.Items[0].Result.Title != "Test Page" {
+		t.Errorf("Expected the crawl result of the first URL, got: %+v", report.Items[0])
+	}
+	if report.Items[0].Summary.LinksChecked != 3 {
+		t.Errorf("Expected 3 checked links, got: %+v", report.Items[0].Summary)
+	}
+	if report.Items[1].Status != crawler.BatchStatusFailed || report.Items[2].Status != crawler.BatchStatusFailed {
+		t.Errorf("Expected the invalid and the broken URL to fail, got: %+v", report.Items[1:])
+	}
+
+	// Empty batches are rejected
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(`{"urls": []}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	expectAPIError("at least one url is required")(t, w.Body.Bytes())
+}
@@ -1,18 +1,22 @@
 package tests
 
 import (
+	"bytes"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
 	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/jobs"
 	"github.com/rewebcan/url-fetcher-home24/internal/util"
 )
 
@@ -56,6 +60,21 @@ func TestCrawlHandler_E2E(t *testing.T) {
 				if !strings.Contains(body, "<p>Login Form:  No </p>") {
 					t.Errorf("Expected login form indicator to show 'No' for page without login form, got: %s", body)
 				}
+				if !regexp.MustCompile(`Broken links in other: [^<]*` + regexp.QuoteMeta(fakeServer.URL+"/broken-link")).MatchString(body) {
+					t.Errorf("Expected the broken links grouped by region in response, got: %s", body)
+				}
+				if !strings.Contains(body, "Heading outline (1 headings, 0 warnings)") || !strings.Contains(body, "h1: Test Page") {
+					t.Errorf("Expected the heading outline in response, got: %s", body)
+				}
+				if !strings.Contains(body, "<td>3.1.1 Language of Page</td><td>The html element has no lang attribute</td>") {
+					t.Errorf("Expected the accessibility issues in response, got: %s", body)
+				}
+				if !strings.Contains(body, "Images: 1 found, 1 broken") {
+					t.Error("Expected the broken image in response")
+				}
+				if !strings.Contains(body, "Organization is missing the required property url") {
+					t.Error("Expected the structured data error in response")
+				}
 				// Check that HTML version is correctly detected and displayed
 				if !strings.Contains(body, "<p>HTML Version: HTML5</p>") {
 					t.Errorf("Expected HTML version to be detected as HTML5, got: %s", body)
@@ -140,10 +159,12 @@ func setupFakeWebsite() *httptest.Server {
 <head>
     <title>Test Page</title>
     <meta name="description" content="Test page for crawling">
+    <script type="application/ld+json">{"@context": "https://schema.org", "@type": "Organization", "name": "Test"}</script>
 </head>
 <body>
     <h1>Test Page</h1>
     <p>This is a test page for the crawler.</p>
+    <img src="/missing.png" alt="Missing">
     <a href="/internal-link">Internal Link</a>
     <a href="https://external.com">External Link</a>
     <a href="/broken-link">Broken Link</a>
@@ -153,7 +174,7 @@ func setupFakeWebsite() *httptest.Server {
 		case "/internal-link":
 			w.Header().Set("Content-Type", "text/html")
 			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("<html><body><h1>Internal Page</h1></body></html>"))
+			_, _ = w.Write([]byte(`<html><body><h1>Internal Page</h1><form action="/login" method="post"><input type="password" name="password"></form></body></html>`))
 		case "/broken-link":
 			w.WriteHeader(http.StatusNotFound)
 		default:
@@ -190,6 +211,17 @@ func setupWebApp() http.Handler {
 	// Create router
 	app := http.NewServeMux()
 	app.HandleFunc("/", crawlCtrl.CrawlHandler)
+	app.HandleFunc("/batch", crawlCtrl.BatchHandler)
+	app.HandleFunc("/api/v1/analyze", crawlCtrl.AnalyzeHandler)
+	app.HandleFunc("/api/v1/batch", crawlCtrl.BatchAnalyzeHandler)
+
+	// Create job manager and routes
+	jobCtrl := jobs.NewJobController(jobs.NewManager(c, logger), logger)
+	app.HandleFunc("POST /api/v1/jobs", jobCtrl.SubmitHandler)
+	app.HandleFunc("GET /api/v1/jobs/{id}", jobCtrl.GetHandler)
+	app.HandleFunc("GET /api/v1/jobs/{id}/events", jobCtrl.EventsHandler)
+	app.HandleFunc("DELETE /api/v1/jobs/{id}", jobCtrl.CancelHandler)
+	app.HandleFunc("GET /jobs/{id}", crawlCtrl.ReportHandler(jobCtrl.Result))
 
 	return app
 }
@@ -244,6 +276,7 @@ func TestCrawlHandler_FormSubmission(t *testing.T) {
 		"https://external.com", // External link
 		"/internal-link",       // Internal link
 		"Login Form:  No ",     // Login form indicator (should be "No" since test page has no password fields)
+		"The page has no canonical link",
 	}
 
 	for _, content := range expectedContent {
@@ -252,3 +285,57 @@ func TestCrawlHandler_FormSubmission(t *testing.T) {
 		}
 	}
 }
+
+// TestBatchHandler_FormSubmission tests a batch of URLs from the textarea and
+// a CSV upload
+func TestBatchHandler_FormSubmission(t *testing.T) {
+	fakeServer := setupFakeWebsite()
+	defer fakeServer.Close()
+
+	app := setupWebApp()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("urls", fakeServer.URL+"/test-page\n\nftp://example.com\n")
+	csvFile, _ := mw.CreateFormFile("csv", "urls.csv")
+	_, _ = io.WriteString(csvFile, "name,url\nInternal,"+fakeServer.URL+"/internal-link\n")
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body := w.Body.String()
+
+	expectedContent := []string{
+		"Batch: 3 URLs, 2 succeeded, 1 failed",
+		"unsupported URL scheme",          // The invalid URL does not abort the batch
+		"<p>Title: Test Page</p>",         // Result of the textarea URL
+		fakeServer.URL + "/internal-link", // Result of the CSV URL
+		"login form: POST " + fakeServer.URL + "/login",
+		"The password form submits over HTTP",
+	}
+
+	for _, content := range expectedContent {
+		if !strings.Contains(body, content) {
+			t.Errorf("Expected response to contain '%s', got: %s", content, body)
+		}
+	}
+
+	// An empty batch is a validation error
+	req = httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader("urls="))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "at least one url is required") {
+		t.Errorf("Expected validation error for empty batch, got: %s", w.Body.String())
+	}
+}
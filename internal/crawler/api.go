@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/robots"
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+// maxRequestBodySize limits the size of JSON request bodies
+const maxRequestBodySize = 1 << 20
+
+// APIError is the body of every failed API response
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// NewCrawlRequestFromJSON decodes a CrawlRequest from a JSON request body
+func NewCrawlRequestFromJSON(w http.ResponseWriter, r *http.Request) (*CrawlRequest, error) {
+	cr := &CrawlRequest{}
+	if err := decodeJSON(w, r, cr); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// NewBatchRequestFromJSON decodes a BatchRequest from a JSON request body
+func NewBatchRequestFromJSON(w http.ResponseWriter, r *http.Request) (*BatchRequest, error) {
+	br := &BatchRequest{}
+	if err := decodeJSON(w, r, br); err != nil {
+		return nil, err
+	}
+
+	return br, nil
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid request body: %s", ErrValidation, err.Error())
+	}
+
+	return nil
+}
+
+// AnalyzeHandler
+// Crawls the URL of a JSON CrawlRequest and responds with the CrawlResult
+func (ctrl *crawlController) AnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		util.WriteJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+		return
+	}
+
+	cr, err := NewCrawlRequestFromJSON(w, r)
+	if err == nil {
+		err = cr.Validate()
+	}
+	if err != nil {
+		ctrl.logger.Warn("Analyze request validation failed", "error", err.Error())
+		WriteError(w, err)
+		return
+	}
+
+	ctrl.logger.Info("Starting analyze", "url", cr.URL)
+
+	ctx, cancel := context.WithTimeout(r.Context(), crawlTimeout)
+	defer cancel()
+
+	crawlResult, err := ctrl.c.Crawl(ctx, cr.URL, cr.CrawlOptions()...)
+	if err != nil {
+		ctrl.logger.Error("Analyze failed", "error", err.Error(), "url", cr.URL)
+		WriteError(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, crawlResult)
+}
+
+// BatchAnalyzeHandler
+// Crawls the URLs of a JSON BatchRequest and responds with the BatchReport.
+// Failing URLs are part of the report and do not fail the request.
+func (ctrl *crawlController) BatchAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		util.WriteJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+		return
+	}
+
+	br, err := NewBatchRequestFromJSON(w, r)
+	if err == nil {
+		err = br.Validate()
+	}
+	if err != nil {
+		ctrl.logger.Warn("Batch request validation failed", "error", err.Error())
+		WriteError(w, err)
+		return
+	}
+
+	report := ctrl.batch.Run(r.Context(), br.URLs, br.CrawlOptions()...)
+
+	util.WriteJSON(w, http.StatusOK, report)
+}
+
+// statusForError maps crawl errors to HTTP status codes. Failures of the
+// analyzed server, like ErrBadStatus, are reported as bad gateway.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, robots.ErrDisallowed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.DeadlineExceeded), fetcher.ClassifyError(err) == fetcher.ErrorClassTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// WriteError writes err as APIError with the status code matching the error
+func WriteError(w http.ResponseWriter, err error) {
+	util.WriteJSON(w, statusForError(err), APIError{Error: err.Error()})
+}
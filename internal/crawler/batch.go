@@ -0,0 +1,285 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// maxBatchURLs limits the number of URLs analyzed in a single batch
+const maxBatchURLs = 100
+
+// BatchStatus is the outcome of crawling a single URL of a batch
+type BatchStatus string
+
+const (
+	BatchStatusOK     BatchStatus = "ok"
+	BatchStatusFailed BatchStatus = "failed"
+)
+
+// BatchSummary counts the pages, links, images and resources of a crawl
+type BatchSummary struct {
+	PagesCrawled    int `json:"pages_crawled"`
+	LinksChecked    int `json:"links_checked"`
+	BrokenLinks     int `json:"broken_links"`
+	SkippedLinks    int `json:"skipped_links"`
+	BrokenImages    int `json:"broken_images"`
+	BrokenResources int `json:"broken_resources"`
+}
+
+func (s *BatchSummary) add(o BatchSummary) {
+	s.PagesCrawled += o.PagesCrawled
+	s.LinksChecked += o.LinksChecked
+	s.BrokenLinks += o.BrokenLinks
+	s.SkippedLinks += o.SkippedLinks
+	s.BrokenImages += o.BrokenImages
+	s.BrokenResources += o.BrokenResources
+}
+
+// BatchItem is the result of crawling a single URL of a batch
+type BatchItem struct {
+	URL      string        `json:"url"`
+	Status   BatchStatus   `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Summary  BatchSummary  `json:"summary"`
+	Result   *CrawlResult  `json:"result,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// BatchReport aggregates the results of all URLs of a batch in the order the
+// URLs were given
+type BatchReport struct {
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Summary   BatchSummary  `json:"summary"`
+	Items     []BatchItem   `json:"results"`
+	Duration  time.Duration `json:"duration_ns"`
+}
+
+// BatchOption is a function that configures the batch runner
+type BatchOption func(*BatchRunner)
+
+// WithBatchWorkers sets how many URLs of a batch are crawled in parallel
+func WithBatchWorkers(n int) BatchOption {
+	return func(b *BatchRunner) {
+		b.workers = n
+	}
+}
+
+// WithBatchBudget sets the number of concurrent requests shared by all
+// crawls of a batch
+func WithBatchBudget(n int) BatchOption {
+	return func(b *BatchRunner) {
+		b.budget = n
+	}
+}
+
+// WithBatchTimeout bounds the crawl of every single URL of a batch
+func WithBatchTimeout(d time.Duration) BatchOption {
+	return func(b *BatchRunner) {
+		b.timeout = d
+	}
+}
+
+// BatchRunner crawls lists of URLs
+type BatchRunner struct {
+	c      Crawler
+	logger *slog.Logger
+
+	workers int
+	budget  int
+	timeout time.Duration
+}
+
+// NewBatchRunner creates a BatchRunner crawling with c
+func NewBatchRunner(c Crawler, logger *slog.Logger, opts ...BatchOption) *BatchRunner {
+	b := &BatchRunner{
+		c:       c,
+		logger:  logger,
+		workers: 4,
+		budget:  10,
+		timeout: crawlTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Run crawls every URL and reports each of them. Invalid or failing URLs are
+// reported as failed without affecting the others. All crawls take their
+// requests from one budget, a concurrency limit among opts replaces the
+// budget of the runner.
+func (b *BatchRunner) Run(ctx context.Context, urls []string, opts ...CrawlOption) *BatchReport {
+	cfg := crawlConfig{concurrencyLimit: b.budget}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	opts = append(append([]CrawlOption{}, opts...), withSharedLimit(semaphore.NewWeighted(int64(cfg.concurrencyLimit))))
+
+	b.logger.Info("Starting batch", "urls", len(urls), "workers", b.workers, "budget", cfg.concurrencyLimit)
+
+	start := time.Now()
+	report := &BatchReport{Items: make([]BatchItem, len(urls))}
+
+	g := new(errgroup.Group)
+	g.SetLimit(b.workers)
+
+	for i, u := range urls {
+		g.Go(func() error {
+			report.Items[i] = b.crawl(ctx, u, opts)
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	for _, item := range report.Items {
+		report.Total++
+		if item.Status == BatchStatusOK {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		report.Summary.add(item.Summary)
+	}
+	report.Duration = time.Since(start)
+
+	b.logger.Info("Batch completed", "urls", report.Total, "failed", report.Failed, "broken_links", report.Summary.BrokenLinks)
+
+	return report
+}
+
+// crawl crawls a single URL of a batch
+func (b *BatchRunner) crawl(ctx context.Context, rawURL string, opts []CrawlOption) BatchItem {
+	item := BatchItem{URL: rawURL}
+	start := time.Now()
+
+	cr := &CrawlRequest{URL: rawURL}
+	err := cr.Validate()
+	if err == nil {
+		item.URL = cr.URL
+
+		ctx, cancel := context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+
+		item.Result, err = b.c.Crawl(ctx, cr.URL, opts...)
+	}
+	item.Duration = time.Since(start)
+
+	if err != nil {
+		b.logger.Warn("Batch URL failed", "url", rawURL, "error", err.Error())
+		item.Status = BatchStatusFailed
+		item.Error = err.Error()
+		return item
+	}
+
+	item.Status = BatchStatusOK
+	for _, page := range item.Result.Pages() {
+		item.Summary.add(BatchSummary{
+			PagesCrawled:    1,
+			LinksChecked:    len(page.LinkChecks),
+			BrokenLinks:     len(page.BrokenLinks()),
+			SkippedLinks:    len(page.SkippedLinks()),
+			BrokenImages:    len(page.BrokenImages()),
+			BrokenResources: len(page.BrokenResources()),
+		})
+	}
+
+	return item
+}
+
+// BatchRequest is a list of URLs analyzed with the same options
+type BatchRequest struct {
+	URLs    []string            `json:"urls"`
+	Options CrawlRequestOptions `json:"options"`
+}
+
+// Validate checks the size of the batch and its options. The URLs are
+// validated one by one when the batch runs.
+func (br *BatchRequest) Validate() error {
+	if len(br.URLs) > maxBatchURLs {
+		return fmt.Errorf("%w: a batch can not have more than %d urls", ErrValidation, maxBatchURLs)
+	}
+
+	return br.ValidateUnlimited()
+}
+
+// ValidateUnlimited checks the batch like Validate without the limit on the
+// number of URLs, which only applies to requests to the API
+func (br *BatchRequest) ValidateUnlimited() error {
+	if len(br.URLs) == 0 {
+		return fmt.Errorf("%w: at least one url is required", ErrValidation)
+	}
+
+	return br.Options.validate()
+}
+
+// CrawlOptions converts the request options into crawl options
+func (br *BatchRequest) CrawlOptions() []CrawlOption {
+	cr := CrawlRequest{Options: br.Options}
+
+	return cr.CrawlOptions()
+}
+
+// ParseURLList reads one URL per line, skipping blank lines
+func ParseURLList(r io.Reader) ([]string, error) {
+	var urls []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if u := strings.TrimSpace(scanner.Text()); u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls, scanner.Err()
+}
+
+// ParseURLCSV reads the URLs of a CSV file. The URLs are taken from the
+// column with the header "url" or, without such a header, from the first
+// column.
+func ParseURLCSV(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid csv: %s", ErrValidation, err.Error())
+	}
+
+	column := 0
+	if len(records) > 0 {
+		for i, cell := range records[0] {
+			if strings.EqualFold(strings.TrimSpace(cell), "url") {
+				column = i
+				records = records[1:]
+				break
+			}
+		}
+	}
+
+	var urls []string
+	for _, record := range records {
+		if column >= len(record) {
+			continue
+		}
+		if u := strings.TrimSpace(record[column]); u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,43 @@
+package crawler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/robots"
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+// NewCrawlerFromConfig creates a Crawler with the limits and politeness
+// described by config. robots.txt files are requested with hc.
+func NewCrawlerFromConfig(f fetcher.Fetcher, hc *http.Client, logger *slog.Logger, config *util.CrawlerConfig) Crawler {
+	opts := []CrawlOption{
+		WithConcurrencyLimit(config.ConcurrencyLimit),
+		WithCrawlDepth(config.CrawlDepth),
+		WithPingStrategy(fetcher.PingStrategy(config.PingStrategy)),
+	}
+
+	if config.HostRateLimit > 0 {
+		opts = append(opts, WithHostRateLimit(config.HostRateLimit, config.HostRateBurst))
+	}
+	if config.AdaptiveConcurrency {
+		opts = append(opts, WithAdaptiveConcurrency(config.MinHostConcurrency, config.ConcurrencyLimit, config.LatencyTarget))
+	}
+	if config.RespectRobots {
+		opts = append(opts, WithRobots(robots.NewChecker(hc, logger, config.UserAgent)))
+	}
+
+	return NewCrawler(f, logger, opts...)
+}
+
+// NewBatchRunnerFromConfig creates a BatchRunner sharing the concurrency limit
+// of config among the crawls of a batch. opts override the configuration.
+func NewBatchRunnerFromConfig(c Crawler, logger *slog.Logger, config *util.CrawlerConfig, opts ...BatchOption) *BatchRunner {
+	opts = append([]BatchOption{
+		WithBatchWorkers(config.BatchWorkers),
+		WithBatchBudget(config.ConcurrencyLimit),
+	}, opts...)
+
+	return NewBatchRunner(c, logger, opts...)
+}
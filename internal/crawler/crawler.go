@@ -2,10 +2,19 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/ratelimit"
+	"github.com/rewebcan/url-fetcher-home24/internal/robots"
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 )
@@ -16,6 +25,17 @@ type CrawlOption func(*crawlConfig)
 // crawlConfig holds internal crawl configuration
 type crawlConfig struct {
 	concurrencyLimit int
+	crawlDepth       int
+	robots           robots.Checker
+	hostLimiter      *ratelimit.HostLimiter
+	adaptive         *ratelimit.AdaptiveLimiter
+	pingStrategy     fetcher.PingStrategy
+	extractors       []string
+	observer         Observer
+
+	// sharedLimit replaces the limit of a single crawl when several crawls
+	// share one concurrency budget
+	sharedLimit *semaphore.Weighted
 }
 
 // WithConcurrencyLimit sets the maximum number of concurrent link pings
@@ -25,8 +45,70 @@ func WithConcurrencyLimit(limit int) CrawlOption {
 	}
 }
 
+// WithCrawlDepth sets how many levels of pages are crawled. A depth of 1 only
+// analyzes the start page, every additional level fetches the internal pages
+// linked from the previous one.
+func WithCrawlDepth(depth int) CrawlOption {
+	return func(c *crawlConfig) {
+		c.crawlDepth = depth
+	}
+}
+
+// WithRobots enables robots.txt enforcement. URLs disallowed for the checker's
+// user-agent are skipped and the crawl-delay of each host is respected.
+func WithRobots(checker robots.Checker) CrawlOption {
+	return func(c *crawlConfig) {
+		c.robots = checker
+	}
+}
+
+// WithHostRateLimit limits the requests sent to every single host to rate per
+// second, allowing bursts of up to burst requests
+func WithHostRateLimit(rate float64, burst int) CrawlOption {
+	return func(c *crawlConfig) {
+		c.hostLimiter = ratelimit.NewHostLimiter(rate, burst)
+	}
+}
+
+// WithAdaptiveConcurrency adapts the number of in-flight requests per host
+// between min and max based on the observed responses. Hosts answering slower
+// than latencyTarget, with 429 or with 5xx get fewer concurrent requests.
+// The concurrency limit still caps the requests across all hosts.
+func WithAdaptiveConcurrency(min, max int, latencyTarget time.Duration) CrawlOption {
+	return func(c *crawlConfig) {
+		c.adaptive = ratelimit.NewAdaptiveLimiter(min, max, latencyTarget)
+	}
+}
+
+// withSharedLimit makes the crawl take its request slots from sem instead of
+// its own concurrency limit
+func withSharedLimit(sem *semaphore.Weighted) CrawlOption {
+	return func(c *crawlConfig) {
+		c.sharedLimit = sem
+	}
+}
+
+// WithPingStrategy sets how links are requested, HEAD first by default
+func WithPingStrategy(strategy fetcher.PingStrategy) CrawlOption {
+	return func(c *crawlConfig) {
+		c.pingStrategy = strategy
+	}
+}
+
+// WithExtractors limits the analysis of every page to the named extractors
+// of the fetcher. Anchors are only followed and checked when the anchors
+// extractor runs.
+func WithExtractors(names ...string) CrawlOption {
+	return func(c *crawlConfig) {
+		c.extractors = names
+	}
+}
+
+// Crawler crawls pages. Options passed to Crawl override the options the
+// crawler was created with for that crawl only. Crawl returns the error of
+// ctx once it is done, not a partial result.
 type Crawler interface {
-	Crawl(ctx context.Context, url string) (*CrawlResult, error)
+	Crawl(ctx context.Context, url string, opts ...CrawlOption) (*CrawlResult, error)
 }
 
 type crawler struct {
@@ -41,6 +123,8 @@ func NewCrawler(f fetcher.Fetcher, logger *slog.Logger, opts ...CrawlOption) Cra
 	// Default configuration
 	config := &crawlConfig{
 		concurrencyLimit: 10,
+		crawlDepth:       1,
+		pingStrategy:     fetcher.PingStrategyHead,
 	}
 
 	// Apply options
@@ -51,17 +135,122 @@ func NewCrawler(f fetcher.Fetcher, logger *slog.Logger, opts ...CrawlOption) Cra
 	return &crawler{f: f, crawlConfig: config, logger: logger}
 }
 
+// CrawlResult is the analysis of a single page. Pages discovered through
+// internal links are attached as Children, forming a tree rooted at the
+// start page.
 type CrawlResult struct {
 	fetcher.FetchResult
-	FailedURLs []fetcher.Anchor
+	Depth          int             `json:"depth"`
+	LinkChecks     []LinkCheck     `json:"link_checks"`
+	ImageChecks    []ImageCheck    `json:"image_checks"`
+	ResourceChecks []ResourceCheck `json:"resource_checks"`
+	Children       []*CrawlResult  `json:"children"`
+}
+
+// BrokenLinks returns the checks of the anchors that could not be reached
+func (r *CrawlResult) BrokenLinks() []LinkCheck {
+	return r.linksWithStatus(LinkStatusBroken)
+}
+
+// BrokenLinksByRegion groups the broken links by the landmark of the page
+// they were found in, like the navigation or the footer
+func (r *CrawlResult) BrokenLinksByRegion() map[fetcher.Region][]LinkCheck {
+	regions := map[fetcher.Region][]LinkCheck{}
+	for _, lc := range r.BrokenLinks() {
+		region := lc.Anchor.Region
+		if region == "" {
+			region = fetcher.RegionOther
+		}
+		regions[region] = append(regions[region], lc)
+	}
+
+	return regions
+}
+
+// SkippedLinks returns the checks of the anchors that were not requested
+func (r *CrawlResult) SkippedLinks() []LinkCheck {
+	return r.linksWithStatus(LinkStatusSkipped)
+}
+
+// BrokenImages returns the checks of the images with a URL that could not be
+// loaded
+func (r *CrawlResult) BrokenImages() []ImageCheck {
+	var checks []ImageCheck
+	for _, ic := range r.ImageChecks {
+		if ic.Broken() {
+			checks = append(checks, ic)
+		}
+	}
+
+	return checks
+}
+
+// BrokenResources returns the checks of the resources that could not be
+// loaded, render blocking scripts and stylesheets first
+func (r *CrawlResult) BrokenResources() []ResourceCheck {
+	var blocking, other []ResourceCheck
+	for _, rc := range r.ResourceChecks {
+		switch {
+		case rc.Status != LinkStatusBroken:
+		case rc.Resource.Blocking():
+			blocking = append(blocking, rc)
+		default:
+			other = append(other, rc)
+		}
+	}
+
+	return append(blocking, other...)
+}
+
+// Pages returns the page and all pages crawled below it in depth-first order
+func (r *CrawlResult) Pages() []*CrawlResult {
+	pages := []*CrawlResult{r}
+	for _, child := range r.Children {
+		pages = append(pages, child.Pages()...)
+	}
+
+	return pages
+}
+
+func (r *CrawlResult) linksWithStatus(status LinkStatus) []LinkCheck {
+	var checks []LinkCheck
+	for _, lc := range r.LinkChecks {
+		if lc.Status == status {
+			checks = append(checks, lc)
+		}
+	}
+
+	return checks
+}
+
+// crawlState is shared by all requests of a single crawl
+type crawlState struct {
+	cfg    *crawlConfig
+	sem    *semaphore.Weighted
+	pacer  *hostPacer
+	events *eventEmitter
 }
 
 // Crawl
-// Crawls a page with given URL
-func (c *crawler) Crawl(ctx context.Context, urlRaw string) (*CrawlResult, error) {
-	c.logger.Info("Starting crawl", "url", urlRaw, "concurrency_limit", c.crawlConfig.concurrencyLimit)
+// Crawls a page with given URL and, depending on the configured depth, the
+// internal pages reachable from it in breadth-first order
+func (c *crawler) Crawl(ctx context.Context, urlRaw string, opts ...CrawlOption) (*CrawlResult, error) {
+	cfg := *c.crawlConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
-	sem := semaphore.NewWeighted(int64(c.crawlConfig.concurrencyLimit))
+	c.logger.Info("Starting crawl", "url", urlRaw, "concurrency_limit", cfg.concurrencyLimit, "crawl_depth", cfg.crawlDepth, "ping_strategy", cfg.pingStrategy)
+
+	st := &crawlState{
+		cfg:    &cfg,
+		sem:    cfg.sharedLimit,
+		pacer:  newHostPacer(),
+		events: &eventEmitter{observer: cfg.observer},
+	}
+	if st.sem == nil {
+		st.sem = semaphore.NewWeighted(int64(cfg.concurrencyLimit))
+	}
 
 	baseUrl, err := url.Parse(urlRaw)
 	if err != nil {
@@ -70,56 +259,263 @@ func (c *crawler) Crawl(ctx context.Context, urlRaw string) (*CrawlResult, error
 	}
 
 	c.logger.Info("Fetching main page", "url", urlRaw)
-	result, err := c.f.Fetch(ctx, urlRaw)
+	root, err := c.crawlPage(ctx, st, urlRaw, 0)
 	if err != nil {
 		c.logger.Error("Failed to fetch main page", "url", urlRaw, "error", err.Error())
 		return nil, err
 	}
 
-	c.logger.Info("Main page fetched successfully", "url", urlRaw, "anchors_found", len(result.Anchors))
+	// The crawl stays on the host the start URL redirected to
+	finalUrl, err := url.Parse(root.FinalURL())
+	if err != nil {
+		finalUrl = baseUrl
+	}
+
+	visited := map[string]struct{}{pageKey(baseUrl): {}, pageKey(finalUrl): {}}
+	level := []*CrawlResult{root}
+	pages := 1
+
+	for depth := 1; depth < st.cfg.crawlDepth && len(level) > 0; depth++ {
+		level = c.crawlLevel(ctx, st, finalUrl.Host, level, visited, depth)
+		pages += len(level)
+	}
+
+	// Links and pages left unchecked would be reported as broken, a canceled
+	// crawl has no result
+	if err := ctx.Err(); err != nil {
+		c.logger.Warn("Crawl canceled", "url", urlRaw, "pages_crawled", pages, "error", err.Error())
+		return nil, err
+	}
+
+	c.logger.Info("Crawl completed", "url", urlRaw, "pages_crawled", pages, "total_anchors", len(root.Anchors), "broken_links", len(root.BrokenLinks()))
+
+	return root, nil
+}
+
+// crawlPage fetches a single page and pings every anchor, image and resource
+// found on it
+func (c *crawler) crawlPage(ctx context.Context, st *crawlState, pageURL string, depth int) (*CrawlResult, error) {
+	baseUrl, result, err := c.fetchPage(ctx, st, pageURL)
+	if err != nil {
+		st.events.pageFailed(pageURL, depth, err)
+		return nil, err
+	}
+
+	c.logger.Info("Page fetched successfully", "url", pageURL, "depth", depth, "anchors_found", len(result.Anchors))
+	st.events.pageFetched(pageURL, depth, result)
 
-	var failedURLs []fetcher.Anchor
+	page := &CrawlResult{FetchResult: *result, Depth: depth}
 
-	failedUrlsC := make(chan fetcher.Anchor, 16)
 	g := new(errgroup.Group)
+	g.Go(func() error {
+		page.LinkChecks = c.checkLinks(ctx, st, baseUrl, pageURL, depth, result.Anchors)
+		return nil
+	})
+	g.Go(func() error {
+		page.ImageChecks = c.checkImages(ctx, st, pageURL, depth, result.Images)
+		return nil
+	})
+	g.Go(func() error {
+		page.ResourceChecks = c.checkResources(ctx, st, pageURL, depth, result.Resources)
+		return nil
+	})
+	_ = g.Wait()
+
+	return page, nil
+}
+
+// fetchPage fetches a single page once the host admits the request and
+// returns its URL after redirects
+func (c *crawler) fetchPage(ctx context.Context, st *crawlState, pageURL string) (*url.URL, *fetcher.FetchResult, error) {
+	baseUrl, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skipReason, err := c.admit(ctx, st, baseUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if skipReason != "" {
+		return nil, nil, fmt.Errorf("%w: %s", robots.ErrDisallowed, pageURL)
+	}
+
+	if err := c.acquire(ctx, st, baseUrl.Host); err != nil {
+		return nil, nil, err
+	}
+	fetchCtx, timer := fetcher.WithAttemptTimer(ctx)
+	start := time.Now()
+	result, err := c.f.Fetch(fetchCtx, pageURL, fetcher.WithExtractors(st.cfg.extractors...))
+	c.release(st, baseUrl.Host, timer.Latency(time.Since(start)), err)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Relative anchors are resolved against the page the request ended at
+	if finalUrl, err := url.Parse(result.FinalURL()); err == nil {
+		baseUrl = finalUrl
+	}
+
+	return baseUrl, result, nil
+}
+
+// admit applies the robots.txt rules, crawl-delay and rate limit of the host
+// before a request is sent to u. It returns a non-empty reason when u must be
+// skipped.
+func (c *crawler) admit(ctx context.Context, st *crawlState, u *url.URL) (string, error) {
+	if st.cfg.robots != nil {
+		rules := st.cfg.robots.Rules(ctx, u)
+		if !rules.Allowed(u) {
+			return robots.ErrDisallowed.Error(), nil
+		}
+
+		if err := st.pacer.wait(ctx, u.Host, rules.CrawlDelay); err != nil {
+			return "", err
+		}
+	}
+
+	if u.Host == "" {
+		return "", nil
+	}
+
+	return "", st.cfg.hostLimiter.Wait(ctx, u.Host)
+}
+
+// acquire takes a slot of the host window and of the crawl wide concurrency
+// limit. The host slot is taken first so requests waiting for a busy host do
+// not block requests to other hosts.
+func (c *crawler) acquire(ctx context.Context, st *crawlState, host string) error {
+	if err := st.cfg.adaptive.Acquire(ctx, host); err != nil {
+		return err
+	}
+
+	if err := st.sem.Acquire(ctx, 1); err != nil {
+		st.cfg.adaptive.Cancel(host)
+		return err
+	}
+
+	return nil
+}
 
-	for _, a := range result.Anchors {
-		a := a
+// release frees the slots taken by acquire and reports the outcome of the
+// request to the adaptive limiter. latency is the duration of the last
+// attempt, retry backoff is not a sign of a slow host.
+func (c *crawler) release(st *crawlState, host string, latency time.Duration, err error) {
+	st.sem.Release(1)
+	st.cfg.adaptive.Release(host, latency, isOverloaded(err))
+}
+
+// isOverloaded reports whether err indicates a struggling server
+func isOverloaded(err error) bool {
+	var statusErr *fetcher.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// crawlLevel crawls every not yet visited internal page linked from the given
+// parents and attaches the results to them. It returns the newly crawled
+// pages, which form the next level of the crawl.
+func (c *crawler) crawlLevel(ctx context.Context, st *crawlState, host string, parents []*CrawlResult, visited map[string]struct{}, depth int) []*CrawlResult {
+	type pageLink struct {
+		parent *CrawlResult
+		url    string
+	}
+
+	var links []pageLink
+	for _, parent := range parents {
+		for _, u := range followLinks(parent, host, visited) {
+			links = append(links, pageLink{parent: parent, url: u})
+		}
+	}
+
+	children := make([]*CrawlResult, len(links))
+	g := new(errgroup.Group)
+
+	for i, l := range links {
 		g.Go(func() error {
-			if err := sem.Acquire(ctx, 1); err != nil {
-				return err
-			}
-			defer sem.Release(1)
-
-			urlStr := a.URL
-			if !a.External {
-				if rel, err := url.Parse(a.URL); err == nil {
-					urlStr = baseUrl.ResolveReference(rel).String()
-				} else {
-					failedUrlsC <- a
-					return nil
-				}
-			}
-			if err := c.f.Ping(ctx, urlStr); err != nil {
-				failedUrlsC <- a
+			child, err := c.crawlPage(ctx, st, l.url, depth)
+			if err != nil {
+				c.logger.Warn("Failed to crawl page", "url", l.url, "depth", depth, "error", err.Error())
+				return nil
 			}
+			children[i] = child
 			return nil
 		})
 	}
 
-	go func() {
-		_ = g.Wait()
-		close(failedUrlsC)
-	}()
+	_ = g.Wait()
 
-	for a := range failedUrlsC {
-		failedURLs = append(failedURLs, a)
+	var next []*CrawlResult
+	for i, child := range children {
+		if child == nil {
+			continue
+		}
+		links[i].parent.Children = append(links[i].parent.Children, child)
+		next = append(next, child)
 	}
 
-	c.logger.Info("Crawl completed", "url", urlRaw, "total_anchors", len(result.Anchors), "failed_urls", len(failedURLs))
+	return next
+}
+
+// followLinks returns the resolved URLs of the internal anchors of a page that
+// point to the crawled host, were reachable and were not visited yet.
+// Returned URLs are marked as visited.
+func followLinks(page *CrawlResult, host string, visited map[string]struct{}) []string {
+	var urls []string
+	for _, lc := range page.LinkChecks {
+		if lc.Status != LinkStatusOK || lc.Anchor.External {
+			continue
+		}
+
+		u, err := url.Parse(lc.URL)
+		if err != nil || !strings.EqualFold(u.Host, host) || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		key := pageKey(u)
+		if _, ok := visited[key]; ok {
+			continue
+		}
+		visited[key] = struct{}{}
+
+		u.Fragment = ""
+		urls = append(urls, u.String())
+	}
+
+	return urls
+}
+
+// resolveAnchor resolves an anchor against the URL of the page it was found on
+func resolveAnchor(baseUrl *url.URL, a fetcher.Anchor) (*url.URL, error) {
+	if a.External {
+		return url.Parse(a.URL)
+	}
+
+	rel, err := url.Parse(a.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return baseUrl.ResolveReference(rel), nil
+}
+
+// pageKey identifies a page regardless of fragments and trivial URL differences
+func pageKey(u *url.URL) string {
+	cp := *u
+	cp.Fragment = ""
+
+	if normalized, err := util.NormalizeURL(cp.String()); err == nil {
+		return normalized
+	}
 
-	return &CrawlResult{
-		FetchResult: *result,
-		FailedURLs:  failedURLs,
-	}, nil
+	return cp.String()
 }
@@ -2,12 +2,19 @@ package crawler
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/rewebcan/url-fetcher-home24/internal/robots"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,8 +30,222 @@ func TestCrawler(t *testing.T) {
 	assert.NotNil(t, r)
 	assert.Nil(t, err)
 
-	assert.NotNil(t, r.FailedURLs)
-	assert.Len(t, r.FailedURLs, 2)
+	assert.Len(t, r.LinkChecks, 2)
+	assert.Len(t, r.BrokenLinks(), 2)
+}
+
+func brokenAnchors(r *CrawlResult) []fetcher.Anchor {
+	var anchors []fetcher.Anchor
+	for _, lc := range r.BrokenLinks() {
+		anchors = append(anchors, lc.Anchor)
+	}
+
+	return anchors
+}
+
+func TestCrawler_LinkChecks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":      {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/sofa"}, {URL: "/missing"}, {URL: "http://[::1"}}},
+		"https://shop.test/sofa": {URL: "https://shop.test/sofa"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Len(t, r.LinkChecks, 3)
+
+	ok := r.LinkChecks[0]
+	assert.Equal(t, LinkStatusOK, ok.Status)
+	assert.Equal(t, "https://shop.test/sofa", ok.URL)
+	assert.Equal(t, 200, ok.StatusCode)
+	assert.Equal(t, "https://shop.test/sofa", ok.FinalURL)
+	assert.Equal(t, fetcher.ErrorClassNone, ok.ErrorClass)
+
+	missing := r.LinkChecks[1]
+	assert.Equal(t, LinkStatusBroken, missing.Status)
+	assert.Equal(t, "https://shop.test/missing", missing.URL)
+	assert.Equal(t, "not found", missing.Error)
+
+	invalid := r.LinkChecks[2]
+	assert.Equal(t, LinkStatusBroken, invalid.Status)
+	assert.Equal(t, fetcher.ErrorClassInvalidURL, invalid.ErrorClass)
+
+	// Anchors differing only in the fragment are requested once
+	counter := &pingCounter{Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":      {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/sofa#colors"}, {URL: "/sofa#reviews"}, {URL: "/sofa"}}},
+		"https://shop.test/sofa": {URL: "https://shop.test/sofa"},
+	})}
+
+	r, err = NewCrawler(counter, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"https://shop.test/sofa": 1}, counter.pings)
+	assert.Len(t, r.LinkChecks, 3)
+	for _, lc := range r.LinkChecks {
+		assert.Equal(t, LinkStatusOK, lc.Status)
+	}
+	assert.Equal(t, "/sofa#reviews", r.LinkChecks[1].Anchor.URL)
+}
+
+func TestCrawler_ImageChecks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {URL: "https://shop.test", Images: []fetcher.Image{
+			{Src: "https://shop.test/sofa.jpg"},
+			{Src: "https://shop.test/missing.jpg"},
+			{Src: "https://shop.test/sofa.jpg"},
+			{Src: "data:image/gif;base64,R0lGODlhAQABAAAAACw="},
+			{Srcset: []fetcher.SrcsetCandidate{{URL: "https://shop.test/sofa.jpg", Descriptor: "1x"}}},
+			{},
+		}},
+		"https://shop.test/sofa.jpg": {},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Len(t, r.ImageChecks, 6)
+
+	var statuses []LinkStatus
+	for _, ic := range r.ImageChecks {
+		statuses = append(statuses, ic.Status)
+	}
+	assert.Equal(t, []LinkStatus{LinkStatusOK, LinkStatusBroken, LinkStatusOK, LinkStatusSkipped, LinkStatusOK, LinkStatusSkipped}, statuses)
+
+	assert.Len(t, r.BrokenImages(), 1)
+	assert.Equal(t, "https://shop.test/missing.jpg", r.BrokenImages()[0].URL)
+	assert.Equal(t, "inline image", r.ImageChecks[3].SkipReason)
+	assert.Equal(t, "image has no source", r.ImageChecks[5].SkipReason)
+
+	// Every srcset and <source> candidate is checked, each URL once
+	counter := &pingCounter{Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {URL: "https://shop.test", Images: []fetcher.Image{
+			{
+				Src:     "https://shop.test/sofa.jpg",
+				Srcset:  []fetcher.SrcsetCandidate{{URL: "https://shop.test/sofa.jpg", Descriptor: "1x"}, {URL: "https://shop.test/sofa-2x.jpg", Descriptor: "2x"}},
+				Sources: []fetcher.ImageSource{{Srcset: []fetcher.SrcsetCandidate{{URL: "https://shop.test/sofa.webp"}}}},
+			},
+			{Src: "https://shop.test/sofa.jpg"},
+		}},
+		"https://shop.test/sofa.jpg":    {},
+		"https://shop.test/sofa-2x.jpg": {},
+	})}
+
+	r, err = NewCrawler(counter, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"https://shop.test/sofa.jpg": 1, "https://shop.test/sofa-2x.jpg": 1, "https://shop.test/sofa.webp": 1}, counter.pings)
+	assert.Equal(t, LinkStatusOK, r.ImageChecks[0].Status)
+	assert.Len(t, r.ImageChecks[0].Candidates, 2)
+	if assert.Len(t, r.BrokenImages(), 1) {
+		broken := r.BrokenImages()[0].BrokenURLs()
+		assert.Len(t, broken, 1)
+		assert.Equal(t, "https://shop.test/sofa.webp", broken[0].URL)
+	}
+}
+
+// pingCounter counts the pings of every URL
+type pingCounter struct {
+	fetcher.Fetcher
+	mu    sync.Mutex
+	pings map[string]int
+}
+
+func (f *pingCounter) Ping(ctx context.Context, url string, opts ...fetcher.PingOption) (*fetcher.PingResult, error) {
+	f.mu.Lock()
+	if f.pings == nil {
+		f.pings = map[string]int{}
+	}
+	f.pings[url]++
+	f.mu.Unlock()
+
+	return f.Fetcher.Ping(ctx, url, opts...)
+}
+
+func TestCrawler_ResourceChecks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := &pingCounter{Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {URL: "https://shop.test", Resources: []fetcher.Resource{
+			{Kind: fetcher.ResourceIcon, URL: "https://shop.test/favicon.ico"},
+			{Kind: fetcher.ResourceStylesheet, URL: "https://shop.test/app.css"},
+			{Kind: fetcher.ResourceScript, URL: "https://shop.test/app.js"},
+			{Kind: fetcher.ResourceFont, URL: "data:font/woff2;base64,d09GMgABAAAAAA"},
+			{Kind: fetcher.ResourceScript, URL: "https://shop.test/app.js"},
+		}},
+		"https://shop.test/app.js": {},
+	})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Len(t, r.ResourceChecks, 5)
+	assert.Equal(t, LinkStatusOK, r.ResourceChecks[2].Status)
+	assert.Equal(t, LinkStatusOK, r.ResourceChecks[4].Status)
+	assert.Equal(t, 1, f.pings["https://shop.test/app.js"])
+	assert.Equal(t, "inline resource", r.ResourceChecks[3].SkipReason)
+	assert.Empty(t, r.BrokenLinks())
+
+	// The broken stylesheet blocks rendering and is reported before the icon
+	var broken []string
+	for _, rc := range r.BrokenResources() {
+		broken = append(broken, rc.URL)
+	}
+	assert.Equal(t, []string{"https://shop.test/app.css", "https://shop.test/favicon.ico"}, broken)
+}
+
+func TestCrawler_UncheckedAnchors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {URL: "https://shop.test", Anchors: []fetcher.Anchor{
+			{URL: "https://shop.test/sofa", Type: fetcher.LinkTypeSameHost},
+			{URL: "https://shop.test#top", Type: fetcher.LinkTypeSamePage},
+			{URL: "mailto:service@shop.test", Type: fetcher.LinkTypeMailto},
+			{URL: "javascript:void(0)", Type: fetcher.LinkTypeJavascript},
+		}},
+		"https://shop.test/sofa": {URL: "https://shop.test/sofa"},
+	})
+
+	var found int
+	observer := ObserverFunc(func(e Event) {
+		found = e.Progress.LinksFound
+	})
+
+	r, err := NewCrawler(f, logger).Crawl(context.Background(), "https://shop.test", WithObserver(observer))
+
+	assert.Nil(t, err)
+	assert.Len(t, r.LinkChecks, 1)
+	assert.Equal(t, "https://shop.test/sofa", r.LinkChecks[0].URL)
+	assert.Equal(t, 1, found)
+}
+
+func TestCrawlResult_BrokenLinksByRegion(t *testing.T) {
+	broken := func(u string, region fetcher.Region) LinkCheck {
+		return LinkCheck{Anchor: fetcher.Anchor{URL: u, Region: region}, URLCheck: URLCheck{URL: u, Status: LinkStatusBroken}}
+	}
+
+	r := &CrawlResult{LinkChecks: []LinkCheck{
+		broken("/gone", fetcher.RegionNav),
+		{Anchor: fetcher.Anchor{URL: "/ok", Region: fetcher.RegionNav}, URLCheck: URLCheck{Status: LinkStatusOK}},
+		broken("/old", fetcher.RegionFooter),
+		broken("/moved", fetcher.RegionNav),
+		broken("/legacy", ""),
+	}}
+
+	regions := r.BrokenLinksByRegion()
+	assert.Len(t, regions, 3)
+	assert.Equal(t, []LinkCheck{broken("/gone", fetcher.RegionNav), broken("/moved", fetcher.RegionNav)}, regions[fetcher.RegionNav])
+	assert.Len(t, regions[fetcher.RegionFooter], 1)
+	assert.Len(t, regions[fetcher.RegionOther], 1)
 }
 
 func TestCrawler_Fail(t *testing.T) {
@@ -39,3 +260,399 @@ func TestCrawler_Fail(t *testing.T) {
 	assert.Nil(t, r)
 	assert.NotNil(t, err)
 }
+
+func TestCrawler_Depth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {
+			URL:     "https://shop.test",
+			Anchors: []fetcher.Anchor{{URL: "/category"}, {URL: "/missing"}, {External: true, URL: "https://other.test/page"}},
+		},
+		"https://shop.test/category": {
+			URL:     "https://shop.test/category",
+			Anchors: []fetcher.Anchor{{URL: "/product"}, {URL: "https://shop.test"}},
+		},
+		"https://shop.test/product": {
+			URL:     "https://shop.test/product",
+			Anchors: []fetcher.Anchor{{URL: "/deeper"}},
+		},
+		"https://other.test/page": {URL: "https://other.test/page"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger, WithCrawlDepth(3)).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, r.Depth)
+	assert.Equal(t, []fetcher.Anchor{{URL: "/missing"}}, brokenAnchors(r))
+
+	// The broken and the external link are not followed
+	assert.Len(t, r.Children, 1)
+	category := r.Children[0]
+	assert.Equal(t, "https://shop.test/category", category.URL)
+	assert.Equal(t, 1, category.Depth)
+
+	// The start page is not crawled twice
+	assert.Len(t, category.Children, 1)
+	product := category.Children[0]
+	assert.Equal(t, "https://shop.test/product", product.URL)
+	assert.Equal(t, 2, product.Depth)
+
+	// The depth limit stops the crawl before /deeper is fetched
+	assert.Empty(t, product.Children)
+	assert.Equal(t, []fetcher.Anchor{{URL: "/deeper"}}, brokenAnchors(product))
+}
+
+func TestCrawler_RedirectedStart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "localhost:") {
+			http.Redirect(w, r, "http://127.0.0.1:"+strings.TrimPrefix(r.Host, "localhost:")+r.URL.Path, http.StatusMovedPermanently)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><body><a href="/sofa">Sofa</a><a href="/chair">Chair</a></body></html>`))
+		default:
+			_, _ = w.Write([]byte(`<html><body><a href="/">Home</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFetcher(server.Client(), logger, 10<<20)
+	start := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger, WithCrawlDepth(3)).Crawl(ctx, start)
+
+	// The pages of the host the start URL redirected to are crawled, the
+	// start page is not crawled again under its final URL
+	assert.Nil(t, err)
+	assert.Len(t, r.Children, 2)
+	for _, child := range r.Children {
+		assert.True(t, strings.HasPrefix(child.URL, server.URL), child.URL)
+		assert.Empty(t, child.Children)
+	}
+}
+
+func TestCrawler_DefaultDepth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":          {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/category"}}},
+		"https://shop.test/category": {URL: "https://shop.test/category"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Empty(t, r.BrokenLinks())
+	assert.Empty(t, r.Children)
+}
+
+type staticRobots struct {
+	rules *robots.Rules
+}
+
+func (s staticRobots) Rules(context.Context, *url.URL) *robots.Rules {
+	return s.rules
+}
+
+func TestCrawler_Robots(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {
+			URL:     "https://shop.test",
+			Anchors: []fetcher.Anchor{{URL: "/category"}, {URL: "/checkout"}, {URL: "/missing"}},
+		},
+		"https://shop.test/category": {URL: "https://shop.test/category"},
+		"https://shop.test/checkout": {URL: "https://shop.test/checkout"},
+	})
+	rules := robots.Parse(strings.NewReader("User-agent: *\nDisallow: /checkout"), "bot")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewCrawler(f, logger, WithCrawlDepth(2), WithRobots(staticRobots{rules: rules}))
+	r, err := c.Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []fetcher.Anchor{{URL: "/missing"}}, brokenAnchors(r))
+	assert.Len(t, r.SkippedLinks(), 1)
+	assert.Equal(t, "https://shop.test/checkout", r.SkippedLinks()[0].URL)
+	assert.Equal(t, "disallowed by robots.txt", r.SkippedLinks()[0].SkipReason)
+	assert.Len(t, r.Children, 1)
+	assert.Equal(t, "https://shop.test/category", r.Children[0].URL)
+
+	_, err = c.Crawl(ctx, "https://shop.test/checkout")
+	assert.ErrorIs(t, err, robots.ErrDisallowed)
+}
+
+func TestCrawler_HostRateLimit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":   {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/a"}, {URL: "/b"}, {URL: "/c"}}},
+		"https://shop.test/a": {URL: "https://shop.test/a"},
+		"https://shop.test/b": {URL: "https://shop.test/b"},
+		"https://shop.test/c": {URL: "https://shop.test/c"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	r, err := NewCrawler(f, logger, WithHostRateLimit(20, 1)).Crawl(ctx, "https://shop.test")
+
+	// One fetch and three pings at 20 requests per second without bursts
+	assert.Nil(t, err)
+	assert.Empty(t, r.BrokenLinks())
+	assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond)
+}
+
+func TestIsOverloaded(t *testing.T) {
+	assert.True(t, isOverloaded(&fetcher.StatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, isOverloaded(fmt.Errorf("wrapped: %w", &fetcher.StatusError{StatusCode: http.StatusBadGateway})))
+	assert.True(t, isOverloaded(context.DeadlineExceeded))
+	assert.False(t, isOverloaded(&fetcher.StatusError{StatusCode: http.StatusNotFound}))
+	assert.False(t, isOverloaded(nil))
+}
+
+func TestCrawler_AdaptiveConcurrency(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":   {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/a"}, {URL: "/b"}, {URL: "/missing"}}},
+		"https://shop.test/a": {URL: "https://shop.test/a"},
+		"https://shop.test/b": {URL: "https://shop.test/b"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := NewCrawler(f, logger, WithAdaptiveConcurrency(1, 1, time.Second)).Crawl(ctx, "https://shop.test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []fetcher.Anchor{{URL: "/missing"}}, brokenAnchors(r))
+}
+
+func TestCrawler_CrawlOptionsOverride(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":          {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/category"}}},
+		"https://shop.test/category": {URL: "https://shop.test/category"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewCrawler(f, logger)
+
+	r, err := c.Crawl(ctx, "https://shop.test", WithCrawlDepth(2), WithPingStrategy(fetcher.PingStrategyGet))
+	assert.Nil(t, err)
+	assert.Len(t, r.Children, 1)
+
+	// The override only applies to a single crawl
+	r, err = c.Crawl(ctx, "https://shop.test")
+	assert.Nil(t, err)
+	assert.Empty(t, r.Children)
+}
+
+func TestCrawler_Observer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test": {
+			URL:       "https://shop.test",
+			Anchors:   []fetcher.Anchor{{URL: "/category"}, {URL: "/gone"}, {URL: "/missing"}},
+			Images:    []fetcher.Image{{Src: "https://shop.test/sofa.jpg"}, {Src: "https://shop.test/missing.jpg"}},
+			Resources: []fetcher.Resource{{Kind: fetcher.ResourceStylesheet, URL: "https://shop.test/app.css"}},
+		},
+		"https://shop.test/category": {URL: "https://shop.test/category", Anchors: []fetcher.Anchor{{URL: "/missing"}}},
+		"https://shop.test/sofa.jpg": {},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var events []Event
+	_, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test", WithCrawlDepth(3), WithObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	})))
+
+	assert.Nil(t, err)
+
+	count := map[EventType]int{}
+	for i, e := range events {
+		count[e.Type]++
+		if i > 0 {
+			assert.GreaterOrEqual(t, e.Progress.LinksChecked, events[i-1].Progress.LinksChecked)
+		}
+	}
+
+	assert.Equal(t, map[EventType]int{
+		EventPageFetched:    2,
+		EventLinkChecked:    1,
+		EventLinkBroken:     3,
+		EventImageChecked:   1,
+		EventImageBroken:    1,
+		EventResourceBroken: 1,
+	}, count)
+	assert.Equal(t, EventPageFetched, events[0].Type)
+	assert.Equal(t, Progress{
+		PagesCrawled:     2,
+		LinksFound:       4,
+		LinksChecked:     4,
+		LinksBroken:      3,
+		ImagesFound:      2,
+		ImagesChecked:    2,
+		ImagesBroken:     1,
+		ResourcesFound:   1,
+		ResourcesChecked: 1,
+		ResourcesBroken:  1,
+	}, events[len(events)-1].Progress)
+
+	events = nil
+	_, err = NewCrawler(f, logger).Crawl(ctx, "https://shop.test/gone", WithObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	})))
+
+	assert.NotNil(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventPageFailed, events[0].Type)
+		assert.Equal(t, Progress{PagesFailed: 1}, events[0].Progress)
+	}
+}
+
+// cancelingFetcher cancels the crawl with the first ping and fails the
+// pings once the crawl is canceled
+type cancelingFetcher struct {
+	fetcher.Fetcher
+	cancel context.CancelFunc
+}
+
+func (f cancelingFetcher) Ping(ctx context.Context, url string, opts ...fetcher.PingOption) (*fetcher.PingResult, error) {
+	f.cancel()
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestCrawler_Canceled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := cancelingFetcher{
+		Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+			"https://shop.test": {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/sofa"}, {URL: "/chair"}, {URL: "/table"}}},
+		}),
+		cancel: cancel,
+	}
+
+	var mu sync.Mutex
+	var links []LinkCheck
+	observer := ObserverFunc(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Link != nil {
+			links = append(links, *e.Link)
+		}
+	})
+
+	// The links waiting for a slot and the one being pinged are not broken
+	r, err := NewCrawler(f, logger).Crawl(ctx, "https://shop.test", WithConcurrencyLimit(1), WithObserver(observer))
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, r)
+	assert.Len(t, links, 3)
+	for _, lc := range links {
+		assert.Equal(t, LinkStatusSkipped, lc.Status)
+		assert.Equal(t, "crawl ended: context canceled", lc.SkipReason)
+	}
+}
+
+// inFlightFetcher records the highest number of concurrent requests
+type inFlightFetcher struct {
+	fetcher.Fetcher
+	mu       sync.Mutex
+	current  int
+	maxCount int
+}
+
+func (f *inFlightFetcher) track() func() {
+	f.mu.Lock()
+	f.current++
+	f.maxCount = max(f.maxCount, f.current)
+	f.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	return func() {
+		f.mu.Lock()
+		f.current--
+		f.mu.Unlock()
+	}
+}
+
+func (f *inFlightFetcher) Fetch(ctx context.Context, url string, opts ...fetcher.FetchOption) (*fetcher.FetchResult, error) {
+	defer f.track()()
+	return f.Fetcher.Fetch(ctx, url, opts...)
+}
+
+func (f *inFlightFetcher) Ping(ctx context.Context, url string, opts ...fetcher.PingOption) (*fetcher.PingResult, error) {
+	defer f.track()()
+	return f.Fetcher.Ping(ctx, url, opts...)
+}
+
+func TestBatchRunner(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := &inFlightFetcher{Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":      {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/a"}, {URL: "/b"}, {URL: "/missing"}}},
+		"https://shop.test/a":    {URL: "https://shop.test/a"},
+		"https://shop.test/b":    {URL: "https://shop.test/b"},
+		"https://blog.test":      {URL: "https://blog.test", Anchors: []fetcher.Anchor{{URL: "/post"}, {URL: "/other"}}},
+		"https://blog.test/post": {URL: "https://blog.test/post"},
+	})}
+
+	b := NewBatchRunner(NewCrawler(f, logger), logger, WithBatchWorkers(3), WithBatchBudget(2))
+
+	report := b.Run(context.Background(), []string{"https://shop.test", "ftp://shop.test", "https://gone.test", "https://blog.test"})
+
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.LessOrEqual(t, f.maxCount, 2)
+
+	statuses := []BatchStatus{}
+	for _, item := range report.Items {
+		statuses = append(statuses, item.Status)
+	}
+	assert.Equal(t, []BatchStatus{BatchStatusOK, BatchStatusFailed, BatchStatusFailed, BatchStatusOK}, statuses)
+	assert.Contains(t, report.Items[1].Error, "unsupported URL scheme")
+	assert.Nil(t, report.Items[2].Result)
+
+	assert.Equal(t, BatchSummary{PagesCrawled: 1, LinksChecked: 3, BrokenLinks: 1}, report.Items[0].Summary)
+	assert.Equal(t, BatchSummary{PagesCrawled: 2, LinksChecked: 5, BrokenLinks: 2}, report.Summary)
+}
+
+func TestParseURLCSV(t *testing.T) {
+	urls, err := ParseURLCSV(strings.NewReader("name,URL\nHome,https://shop.test\n\nSale, https://shop.test/sale\nBroken\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://shop.test", "https://shop.test/sale"}, urls)
+
+	urls, err = ParseURLCSV(strings.NewReader("https://shop.test,landing\nhttps://blog.test\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://shop.test", "https://blog.test"}, urls)
+
+	_, err = ParseURLCSV(strings.NewReader("\"https://shop.test\n"))
+	assert.ErrorIs(t, err, ErrValidation)
+
+	urls, err = ParseURLList(strings.NewReader("https://shop.test\n\n  https://blog.test  \n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://shop.test", "https://blog.test"}, urls)
+}
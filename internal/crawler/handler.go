@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
@@ -15,6 +16,11 @@ import (
 
 var ErrValidation = errors.New("validation error")
 
+// crawlTimeout bounds the duration of a crawl run inside a request. With a
+// host rate limit of r requests per second a crawl reaches at most
+// r*crawlTimeout links, images and resources of the analyzed host.
+const crawlTimeout = 30 * time.Second
+
 func NewCrawlRequestFromRequest(r *http.Request) *CrawlRequest {
 	cr := &CrawlRequest{URL: r.FormValue("url")}
 
@@ -24,28 +30,53 @@ func NewCrawlRequestFromRequest(r *http.Request) *CrawlRequest {
 type crawlController struct {
 	f            fetcher.Fetcher
 	c            Crawler
+	batch        *BatchRunner
 	logger       *slog.Logger
 	templatePath string
 }
 
-func NewCrawlController(f fetcher.Fetcher, c Crawler, l *slog.Logger) *crawlController {
-	return &crawlController{f: f, c: c, logger: l, templatePath: "views/index.html"}
+// ControllerOption is a function that configures the crawl controller
+type ControllerOption func(*crawlController)
+
+// WithBatchRunner sets the runner of batch requests
+func WithBatchRunner(b *BatchRunner) ControllerOption {
+	return func(ctrl *crawlController) {
+		ctrl.batch = b
+	}
 }
 
-func NewCrawlControllerWithTemplate(f fetcher.Fetcher, c Crawler, l *slog.Logger, templatePath string) *crawlController {
-	return &crawlController{f: f, c: c, logger: l, templatePath: templatePath}
+func NewCrawlController(f fetcher.Fetcher, c Crawler, l *slog.Logger, opts ...ControllerOption) *crawlController {
+	return NewCrawlControllerWithTemplate(f, c, l, "views/index.html", opts...)
 }
 
-func (ctrl *crawlController) CrawlHandler(w http.ResponseWriter, r *http.Request) {
-	var crawlResult *CrawlResult
+func NewCrawlControllerWithTemplate(f fetcher.Fetcher, c Crawler, l *slog.Logger, templatePath string, opts ...ControllerOption) *crawlController {
+	ctrl := &crawlController{f: f, c: c, logger: l, templatePath: templatePath}
 
-	ctrl.logger.Info("CrawlHandler started", "method", r.Method, "url", r.URL.Path)
+	for _, opt := range opts {
+		opt(ctrl)
+	}
+
+	if ctrl.batch == nil {
+		ctrl.batch = NewBatchRunner(c, l)
+	}
+
+	return ctrl
+}
 
-	t := template.Must(
+func (ctrl *crawlController) template() *template.Template {
+	return template.Must(
 		template.New("index.html").
 			Funcs(funcMap).
 			ParseFiles(ctrl.templatePath),
 	)
+}
+
+func (ctrl *crawlController) CrawlHandler(w http.ResponseWriter, r *http.Request) {
+	var crawlResult *CrawlResult
+
+	ctrl.logger.Info("CrawlHandler started", "method", r.Method, "url", r.URL.Path)
+
+	t := ctrl.template()
 
 	if r.Method == "POST" {
 		cr := NewCrawlRequestFromRequest(r)
@@ -64,7 +95,7 @@ func (ctrl *crawlController) CrawlHandler(w http.ResponseWriter, r *http.Request
 		var err error
 
 		// Create context with timeout to prevent long-running operations
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), crawlTimeout)
 		defer cancel()
 
 		crawlResult, err = ctrl.c.Crawl(ctx, cr.URL)
@@ -78,7 +109,7 @@ func (ctrl *crawlController) CrawlHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 
-		ctrl.logger.Info("Crawl completed successfully", "url", cr.URL, "anchors_found", len(crawlResult.Anchors), "failed_urls", len(crawlResult.FailedURLs))
+		ctrl.logger.Info("Crawl completed successfully", "url", cr.URL, "anchors_found", len(crawlResult.Anchors), "broken_links", len(crawlResult.BrokenLinks()))
 	}
 
 	_ = t.Execute(w, CrawlPageResponse{
@@ -87,13 +118,128 @@ func (ctrl *crawlController) CrawlHandler(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// ReportHandler
+// Renders the crawl result load returns for the request, like the result of a
+// background job. Results that can not be loaded are answered with 404.
+func (ctrl *crawlController) ReportHandler(load func(r *http.Request) (*CrawlResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := ctrl.template()
+
+		crawlResult, err := load(r)
+		if err != nil {
+			ctrl.logger.Warn("Report not available", "path", r.URL.Path, "error", err.Error())
+			w.WriteHeader(http.StatusNotFound)
+			_ = t.Execute(w, CrawlPageResponse{Errors: []string{err.Error()}})
+			return
+		}
+
+		_ = t.Execute(w, CrawlPageResponse{CrawlResult: crawlResult})
+	}
+}
+
+// BatchHandler
+// Crawls the URLs of the batch form, given one per line or as CSV upload, and
+// renders the batch report
+func (ctrl *crawlController) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	t := ctrl.template()
+
+	if r.Method != http.MethodPost {
+		_ = t.Execute(w, CrawlPageResponse{})
+		return
+	}
+
+	br, err := NewBatchRequestFromForm(w, r)
+	if err == nil {
+		err = br.Validate()
+	}
+	if err != nil {
+		ctrl.logger.Warn("Batch request validation failed", "error", err.Error())
+		_ = t.Execute(w, CrawlPageResponse{Errors: []string{err.Error()}})
+		return
+	}
+
+	report := ctrl.batch.Run(r.Context(), br.URLs, br.CrawlOptions()...)
+
+	_ = t.Execute(w, CrawlPageResponse{Batch: report})
+}
+
+// maxUploadSize limits the size of the batch form including the CSV upload
+const maxUploadSize = 1 << 20
+
+// NewBatchRequestFromForm reads the URLs of the batch form field "urls" and
+// of the uploaded CSV file "csv"
+func NewBatchRequestFromForm(w http.ResponseWriter, r *http.Request) (*BatchRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, fmt.Errorf("%w: invalid form: %s", ErrValidation, err.Error())
+	}
+
+	br := &BatchRequest{}
+
+	urls, err := ParseURLList(strings.NewReader(r.FormValue("urls")))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid url list: %s", ErrValidation, err.Error())
+	}
+	br.URLs = append(br.URLs, urls...)
+
+	file, _, err := r.FormFile("csv")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return br, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid upload: %s", ErrValidation, err.Error())
+	}
+	defer file.Close()
+
+	urls, err = ParseURLCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	br.URLs = append(br.URLs, urls...)
+
+	return br, nil
+}
+
 type CrawlPageResponse struct {
 	CrawlResult *CrawlResult
+	Batch       *BatchReport
 	Errors      []string
 }
 
 type CrawlRequest struct {
-	URL string
+	URL     string              `json:"url"`
+	Options CrawlRequestOptions `json:"options"`
+}
+
+// CrawlRequestOptions are the per crawl options a client may set. Zero values
+// keep the defaults of the crawler.
+type CrawlRequestOptions struct {
+	Depth        int    `json:"depth"`
+	Concurrency  int    `json:"concurrency"`
+	PingStrategy string `json:"ping_strategy"`
+}
+
+const (
+	maxRequestDepth       = 5
+	maxRequestConcurrency = 50
+)
+
+// CrawlOptions converts the request options into crawl options
+func (cr *CrawlRequest) CrawlOptions() []CrawlOption {
+	var opts []CrawlOption
+
+	if cr.Options.Depth > 0 {
+		opts = append(opts, WithCrawlDepth(cr.Options.Depth))
+	}
+	if cr.Options.Concurrency > 0 {
+		opts = append(opts, WithConcurrencyLimit(cr.Options.Concurrency))
+	}
+	if cr.Options.PingStrategy != "" {
+		opts = append(opts, WithPingStrategy(fetcher.PingStrategy(cr.Options.PingStrategy)))
+	}
+
+	return opts
 }
 
 func (cr *CrawlRequest) Validate() error {
@@ -101,6 +247,10 @@ func (cr *CrawlRequest) Validate() error {
 		return fmt.Errorf("%w: url string can not be empty", ErrValidation)
 	}
 
+	if err := cr.Options.validate(); err != nil {
+		return err
+	}
+
 	normalizedURL, err := util.NormalizeURL(cr.URL)
 	if err != nil {
 		return fmt.Errorf("%w: %s", ErrValidation, err.Error())
@@ -114,14 +264,26 @@ func (cr *CrawlRequest) Validate() error {
 	return nil
 }
 
-var funcMap = template.FuncMap{
-	"containsUrl": func(anchors []fetcher.Anchor, str string) bool {
-		for _, a := range anchors {
-			if a.URL == str {
-				return true
-			}
-		}
+func (o CrawlRequestOptions) validate() error {
+	if o.Depth < 0 || o.Depth > maxRequestDepth {
+		return fmt.Errorf("%w: depth must be between 1 and %d", ErrValidation, maxRequestDepth)
+	}
+
+	if o.Concurrency < 0 || o.Concurrency > maxRequestConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 1 and %d", ErrValidation, maxRequestConcurrency)
+	}
+
+	switch fetcher.PingStrategy(o.PingStrategy) {
+	case "", fetcher.PingStrategyHead, fetcher.PingStrategyGet:
+	default:
+		return fmt.Errorf("%w: unsupported ping strategy: %s", ErrValidation, o.PingStrategy)
+	}
 
-		return false
+	return nil
+}
+
+var funcMap = template.FuncMap{
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
 	},
 }
@@ -0,0 +1,91 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"golang.org/x/sync/errgroup"
+)
+
+// ImageCheck is the result of requesting an image of a page. The embedded
+// check is the one of the URL browsers without srcset support load,
+// Candidates are the checks of the other srcset and <source> URLs.
+type ImageCheck struct {
+	Image fetcher.Image `json:"image"`
+	URLCheck
+	Candidates []URLCheck `json:"candidates,omitempty"`
+}
+
+// Broken reports whether any URL of the image could not be loaded
+func (ic ImageCheck) Broken() bool {
+	return len(ic.BrokenURLs()) > 0
+}
+
+// BrokenURLs returns the checks of the URLs of the image that could not be
+// loaded
+func (ic ImageCheck) BrokenURLs() []URLCheck {
+	var broken []URLCheck
+	for _, uc := range append([]URLCheck{ic.URLCheck}, ic.Candidates...) {
+		if uc.Status == LinkStatusBroken {
+			broken = append(broken, uc)
+		}
+	}
+
+	return broken
+}
+
+// checkImages pings every URL of the images of a page concurrently and
+// returns a check for every image in the order of the images. URLs shared by
+// several images or candidates are requested once.
+func (c *crawler) checkImages(ctx context.Context, st *crawlState, pageURL string, depth int, images []fetcher.Image) []ImageCheck {
+	var urls []string
+	for _, img := range images {
+		urls = append(urls, img.URLs()...)
+	}
+	results := c.checkEmbeddedURLs(ctx, st, urls, "image")
+
+	checks := make([]ImageCheck, len(images))
+	for i, img := range images {
+		urls := img.URLs()
+
+		checks[i] = ImageCheck{Image: img, URLCheck: results[urls[0]]}
+		for _, u := range urls[1:] {
+			checks[i].Candidates = append(checks[i].Candidates, results[u])
+		}
+		st.events.imageChecked(pageURL, depth, checks[i])
+	}
+
+	return checks
+}
+
+// checkEmbeddedURLs pings every distinct URL concurrently and returns the
+// checks keyed by URL
+func (c *crawler) checkEmbeddedURLs(ctx context.Context, st *crawlState, urls []string, what string) map[string]URLCheck {
+	var unique []string
+	seen := map[string]bool{}
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			unique = append(unique, u)
+		}
+	}
+
+	checks := make([]URLCheck, len(unique))
+	g := new(errgroup.Group)
+
+	for i, u := range unique {
+		g.Go(func() error {
+			checks[i] = c.checkEmbedded(ctx, st, u, what)
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	results := make(map[string]URLCheck, len(unique))
+	for i, u := range unique {
+		results[u] = checks[i]
+	}
+
+	return results
+}
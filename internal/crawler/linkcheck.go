@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"golang.org/x/sync/errgroup"
+)
+
+// LinkStatus is the outcome of a link check
+type LinkStatus string
+
+const (
+	LinkStatusOK      LinkStatus = "ok"
+	LinkStatusBroken  LinkStatus = "broken"
+	LinkStatusSkipped LinkStatus = "skipped"
+)
+
+// URLCheck is the result of requesting a URL found on a page
+type URLCheck struct {
+	URL        string                `json:"url"`
+	Status     LinkStatus            `json:"status"`
+	Method     string                `json:"method,omitempty"`
+	StatusCode int                   `json:"status_code,omitempty"`
+	FinalURL   string                `json:"final_url,omitempty"`
+	Redirects  fetcher.RedirectChain `json:"redirects"`
+	ErrorClass fetcher.ErrorClass    `json:"error_class,omitempty"`
+	Error      string                `json:"error,omitempty"`
+	SkipReason string                `json:"skip_reason,omitempty"`
+	Latency    time.Duration         `json:"latency_ns"`
+}
+
+// LinkCheck is the result of checking a single anchor of a page
+type LinkCheck struct {
+	Anchor fetcher.Anchor `json:"anchor"`
+	URLCheck
+}
+
+// checkableAnchors returns the anchors leading to web pages. Same page,
+// mailto, tel, javascript and data links are not requested.
+func checkableAnchors(anchors []fetcher.Anchor) []fetcher.Anchor {
+	var checkable []fetcher.Anchor
+	for _, a := range anchors {
+		if a.Checkable() {
+			checkable = append(checkable, a)
+		}
+	}
+
+	return checkable
+}
+
+// checkLinks pings the checkable anchors of a page concurrently and returns a
+// check for every one of them in the order of the anchors. Anchors leading to
+// the same URL, apart from the fragment, are requested once.
+func (c *crawler) checkLinks(ctx context.Context, st *crawlState, baseUrl *url.URL, pageURL string, depth int, anchors []fetcher.Anchor) []LinkCheck {
+	anchors = checkableAnchors(anchors)
+	checks := make([]LinkCheck, len(anchors))
+
+	byKey := map[string][]int{}
+	var keys []string
+	targets := map[string]*url.URL{}
+
+	for i, a := range anchors {
+		checks[i] = LinkCheck{Anchor: a, URLCheck: URLCheck{URL: a.URL}}
+
+		u, err := resolveAnchor(baseUrl, a)
+		if err != nil {
+			checks[i].URLCheck = checks[i].failed(nil, err)
+			st.events.linkChecked(pageURL, depth, checks[i])
+			continue
+		}
+		u.Fragment = ""
+
+		key := pageKey(u)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+			targets[key] = u
+		}
+		byKey[key] = append(byKey[key], i)
+	}
+
+	g := new(errgroup.Group)
+
+	for _, key := range keys {
+		g.Go(func() error {
+			uc := c.checkURL(ctx, st, targets[key])
+			for _, i := range byKey[key] {
+				checks[i].URLCheck = uc
+				st.events.linkChecked(pageURL, depth, checks[i])
+			}
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	return checks
+}
+
+// checkURL pings a single URL once the host admits the request
+func (c *crawler) checkURL(ctx context.Context, st *crawlState, u *url.URL) URLCheck {
+	uc := URLCheck{URL: u.String()}
+
+	skipReason, err := c.admit(ctx, st, u)
+	if err != nil {
+		return uc.failedIn(ctx, nil, err)
+	}
+	if skipReason != "" {
+		uc.Status = LinkStatusSkipped
+		uc.SkipReason = skipReason
+		return uc
+	}
+
+	if err := c.acquire(ctx, st, u.Host); err != nil {
+		return uc.failedIn(ctx, nil, err)
+	}
+	pingCtx, timer := fetcher.WithAttemptTimer(ctx)
+	start := time.Now()
+	res, err := c.f.Ping(pingCtx, uc.URL, fetcher.WithPingStrategy(st.cfg.pingStrategy))
+	uc.Latency = time.Since(start)
+	c.release(st, u.Host, timer.Latency(uc.Latency), err)
+
+	if err != nil {
+		return uc.failedIn(ctx, res, err)
+	}
+
+	uc.Status = LinkStatusOK
+	uc.applyPing(res)
+
+	return uc
+}
+
+// checkEmbedded pings the URL of an image or resource a page loads. Missing
+// and inline URLs, like data URLs, are skipped.
+func (c *crawler) checkEmbedded(ctx context.Context, st *crawlState, rawURL, what string) URLCheck {
+	uc := URLCheck{URL: rawURL, Status: LinkStatusSkipped}
+
+	if rawURL == "" {
+		uc.SkipReason = what + " has no source"
+		return uc
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return uc.failed(nil, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		uc.SkipReason = "inline " + what
+		return uc
+	}
+
+	return c.checkURL(ctx, st, u)
+}
+
+func (uc URLCheck) failed(res *fetcher.PingResult, err error) URLCheck {
+	uc.Status = LinkStatusBroken
+	uc.ErrorClass = fetcher.ClassifyError(err)
+	uc.Error = err.Error()
+	uc.applyPing(res)
+
+	return uc
+}
+
+// failedIn is failed for a request sent with ctx. A URL whose check ended
+// with the crawl is skipped, it is not known to be broken.
+func (uc URLCheck) failedIn(ctx context.Context, res *fetcher.PingResult, err error) URLCheck {
+	if ctx.Err() == nil {
+		return uc.failed(res, err)
+	}
+
+	uc.Status = LinkStatusSkipped
+	uc.SkipReason = "crawl ended: " + ctx.Err().Error()
+
+	return uc
+}
+
+func (uc *URLCheck) applyPing(res *fetcher.PingResult) {
+	if res == nil {
+		return
+	}
+
+	uc.Method = res.Method
+	uc.StatusCode = res.StatusCode
+	uc.FinalURL = res.FinalURL
+	uc.Redirects = res.Redirects
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"sync"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+)
+
+// EventType identifies what happened during a crawl
+type EventType string
+
+const (
+	EventPageFetched EventType = "page_fetched"
+	EventPageFailed  EventType = "page_failed"
+	EventLinkChecked EventType = "link_checked"
+	EventLinkBroken  EventType = "link_broken"
+
+	EventImageChecked    EventType = "image_checked"
+	EventImageBroken     EventType = "image_broken"
+	EventResourceChecked EventType = "resource_checked"
+	EventResourceBroken  EventType = "resource_broken"
+)
+
+// Progress is a snapshot of the work done by a running crawl
+type Progress struct {
+	PagesCrawled int `json:"pages_crawled"`
+	PagesFailed  int `json:"pages_failed"`
+	LinksFound   int `json:"links_found"`
+	LinksChecked int `json:"links_checked"`
+	LinksBroken  int `json:"links_broken"`
+
+	ImagesFound      int `json:"images_found"`
+	ImagesChecked    int `json:"images_checked"`
+	ImagesBroken     int `json:"images_broken"`
+	ResourcesFound   int `json:"resources_found"`
+	ResourcesChecked int `json:"resources_checked"`
+	ResourcesBroken  int `json:"resources_broken"`
+}
+
+// Event is emitted by a running crawl whenever a page was fetched or failed
+// and whenever a link, image or resource was checked. Broken links are
+// reported as EventLinkBroken, every other link as EventLinkChecked, and
+// likewise for images and resources.
+type Event struct {
+	Type     EventType      `json:"type"`
+	PageURL  string         `json:"page_url"`
+	Depth    int            `json:"depth"`
+	Title    string         `json:"title,omitempty"`
+	Link     *LinkCheck     `json:"link,omitempty"`
+	Image    *ImageCheck    `json:"image,omitempty"`
+	Resource *ResourceCheck `json:"resource,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Progress Progress       `json:"progress"`
+}
+
+// Observer receives the events of a crawl. OnEvent is called one event at a
+// time, in the order of the progress, and must not block.
+type Observer interface {
+	OnEvent(e Event)
+}
+
+// ObserverFunc adapts a function to an Observer
+type ObserverFunc func(e Event)
+
+func (fn ObserverFunc) OnEvent(e Event) {
+	fn(e)
+}
+
+// WithObserver reports the events of a crawl to o
+func WithObserver(o Observer) CrawlOption {
+	return func(c *crawlConfig) {
+		c.observer = o
+	}
+}
+
+// eventEmitter keeps the progress of a crawl and passes it to the observer
+// along with every event
+type eventEmitter struct {
+	mu       sync.Mutex
+	progress Progress
+	observer Observer
+}
+
+func (e *eventEmitter) pageFetched(pageURL string, depth int, r *fetcher.FetchResult) {
+	e.emit(Event{Type: EventPageFetched, PageURL: pageURL, Depth: depth, Title: r.Title}, func(p *Progress) {
+		p.PagesCrawled++
+		p.LinksFound += len(checkableAnchors(r.Anchors))
+		p.ImagesFound += len(r.Images)
+		p.ResourcesFound += len(r.Resources)
+	})
+}
+
+func (e *eventEmitter) pageFailed(pageURL string, depth int, err error) {
+	e.emit(Event{Type: EventPageFailed, PageURL: pageURL, Depth: depth, Error: err.Error()}, func(p *Progress) {
+		p.PagesFailed++
+	})
+}
+
+func (e *eventEmitter) linkChecked(pageURL string, depth int, lc LinkCheck) {
+	ev := Event{Type: EventLinkChecked, PageURL: pageURL, Depth: depth, Link: &lc}
+	if lc.Status == LinkStatusBroken {
+		ev.Type = EventLinkBroken
+	}
+
+	e.emit(ev, func(p *Progress) {
+		p.LinksChecked++
+		if lc.Status == LinkStatusBroken {
+			p.LinksBroken++
+		}
+	})
+}
+
+func (e *eventEmitter) imageChecked(pageURL string, depth int, ic ImageCheck) {
+	ev := Event{Type: EventImageChecked, PageURL: pageURL, Depth: depth, Image: &ic}
+	if ic.Broken() {
+		ev.Type = EventImageBroken
+	}
+
+	e.emit(ev, func(p *Progress) {
+		p.ImagesChecked++
+		if ic.Broken() {
+			p.ImagesBroken++
+		}
+	})
+}
+
+func (e *eventEmitter) resourceChecked(pageURL string, depth int, rc ResourceCheck) {
+	ev := Event{Type: EventResourceChecked, PageURL: pageURL, Depth: depth, Resource: &rc}
+	if rc.Status == LinkStatusBroken {
+		ev.Type = EventResourceBroken
+	}
+
+	e.emit(ev, func(p *Progress) {
+		p.ResourcesChecked++
+		if rc.Status == LinkStatusBroken {
+			p.ResourcesBroken++
+		}
+	})
+}
+
+func (e *eventEmitter) emit(ev Event, update func(p *Progress)) {
+	if e.observer == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	update(&e.progress)
+	ev.Progress = e.progress
+
+	e.observer.OnEvent(ev)
+}
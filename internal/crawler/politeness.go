@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostPacer spaces out requests to the same host by a minimum delay
+type hostPacer struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostPacer() *hostPacer {
+	return &hostPacer{next: make(map[string]time.Time)}
+}
+
+// wait reserves the next free slot for host and blocks until it is reached
+func (p *hostPacer) wait(ctx context.Context, host string, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	p.mu.Lock()
+	at := p.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	p.next[host] = at.Add(delay)
+	p.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
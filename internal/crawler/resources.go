@@ -0,0 +1,33 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+)
+
+// ResourceCheck is the result of requesting a script, stylesheet, font,
+// frame or media file of a page
+type ResourceCheck struct {
+	Resource fetcher.Resource `json:"resource"`
+	URLCheck
+}
+
+// checkResources pings the resources of a page concurrently and returns a
+// check for every one of them in the order of the resources. Resources
+// sharing a URL are requested once.
+func (c *crawler) checkResources(ctx context.Context, st *crawlState, pageURL string, depth int, resources []fetcher.Resource) []ResourceCheck {
+	urls := make([]string, len(resources))
+	for i, res := range resources {
+		urls[i] = res.URL
+	}
+	results := c.checkEmbeddedURLs(ctx, st, urls, "resource")
+
+	checks := make([]ResourceCheck, len(resources))
+	for i, res := range resources {
+		checks[i] = ResourceCheck{Resource: res, URLCheck: results[res.URL]}
+		st.events.resourceChecked(pageURL, depth, checks[i])
+	}
+
+	return checks
+}
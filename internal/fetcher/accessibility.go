@@ -0,0 +1,346 @@
+package fetcher
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Severity tells how much an accessibility issue hinders users. Errors make
+// content unusable for assistive technology, warnings make it harder to use.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// WCAG success criteria the accessibility issues refer to
+const (
+	wcagNonTextContent = "1.1.1 Non-text Content"
+	wcagInfoRelations  = "1.3.1 Info and Relationships"
+	wcagLinkPurpose    = "2.4.4 Link Purpose (In Context)"
+	wcagLanguage       = "3.1.1 Language of Page"
+	wcagNameRoleValue  = "4.1.2 Name, Role, Value"
+)
+
+// AccessibilityIssue is a WCAG failure found in the HTML of a page. Criterion
+// is the WCAG 2 success criterion it fails.
+type AccessibilityIssue struct {
+	Code      string   `json:"code"`
+	Severity  Severity `json:"severity"`
+	Criterion string   `json:"criterion"`
+	Message   string   `json:"message"`
+}
+
+// ariaRoles are the non-abstract roles of WAI-ARIA 1.2, DPUB-ARIA and the
+// Graphics ARIA module
+var ariaRoles = map[string]bool{
+	"alert": true, "alertdialog": true, "application": true, "article": true, "banner": true,
+	"blockquote": true, "button": true, "caption": true, "cell": true, "checkbox": true,
+	"code": true, "columnheader": true, "combobox": true, "complementary": true, "contentinfo": true,
+	"definition": true, "deletion": true, "dialog": true, "directory": true, "document": true,
+	"emphasis": true, "feed": true, "figure": true, "form": true, "generic": true,
+	"grid": true, "gridcell": true, "group": true, "heading": true, "img": true,
+	"insertion": true, "link": true, "list": true, "listbox": true, "listitem": true,
+	"log": true, "main": true, "marquee": true, "math": true, "menu": true,
+	"menubar": true, "menuitem": true, "menuitemcheckbox": true, "menuitemradio": true, "meter": true,
+	"navigation": true, "none": true, "note": true, "option": true, "paragraph": true,
+	"presentation": true, "progressbar": true, "radio": true, "radiogroup": true, "region": true,
+	"row": true, "rowgroup": true, "rowheader": true, "scrollbar": true, "search": true,
+	"searchbox": true, "separator": true, "slider": true, "spinbutton": true, "status": true,
+	"strong": true, "subscript": true, "superscript": true, "switch": true, "tab": true,
+	"table": true, "tablist": true, "tabpanel": true, "term": true, "textbox": true,
+	"time": true, "timer": true, "toolbar": true, "tooltip": true, "tree": true,
+	"treegrid": true, "treeitem": true,
+
+	"doc-abstract": true, "doc-acknowledgments": true, "doc-afterword": true, "doc-appendix": true, "doc-backlink": true,
+	"doc-biblioentry": true, "doc-bibliography": true, "doc-biblioref": true, "doc-chapter": true, "doc-colophon": true,
+	"doc-conclusion": true, "doc-cover": true, "doc-credit": true, "doc-credits": true, "doc-dedication": true,
+	"doc-endnote": true, "doc-endnotes": true, "doc-epigraph": true, "doc-epilogue": true, "doc-errata": true,
+	"doc-example": true, "doc-footnote": true, "doc-foreword": true, "doc-glossary": true, "doc-glossref": true,
+	"doc-index": true, "doc-introduction": true, "doc-noteref": true, "doc-notice": true, "doc-pagebreak": true,
+	"doc-pagefooter": true, "doc-pageheader": true, "doc-pagelist": true, "doc-part": true, "doc-preface": true,
+	"doc-prologue": true, "doc-pullquote": true, "doc-qna": true, "doc-subtitle": true, "doc-tip": true,
+	"doc-toc": true,
+
+	"graphics-document": true, "graphics-object": true, "graphics-symbol": true,
+}
+
+// ariaRole resolves a role attribute like browsers do: the first known role
+// of the space separated list wins, the others are fallbacks. It returns an
+// empty string when no role of the list is known.
+func ariaRole(value string) string {
+	for _, role := range strings.Fields(strings.ToLower(value)) {
+		if ariaRoles[role] {
+			return role
+		}
+	}
+
+	return ""
+}
+
+// ariaAttributes are the states and properties of WAI-ARIA 1.2 and 1.3
+var ariaAttributes = map[string]bool{
+	"aria-activedescendant": true, "aria-atomic": true, "aria-autocomplete": true, "aria-braillelabel": true,
+	"aria-brailleroledescription": true, "aria-busy": true, "aria-checked": true, "aria-colcount": true,
+	"aria-colindex": true, "aria-colindextext": true, "aria-colspan": true, "aria-controls": true,
+	"aria-current": true, "aria-describedby": true, "aria-description": true, "aria-details": true,
+	"aria-disabled": true, "aria-dropeffect": true, "aria-errormessage": true, "aria-expanded": true,
+	"aria-flowto": true, "aria-grabbed": true, "aria-haspopup": true, "aria-hidden": true,
+	"aria-invalid": true, "aria-keyshortcuts": true, "aria-label": true, "aria-labelledby": true,
+	"aria-level": true, "aria-live": true, "aria-modal": true, "aria-multiline": true,
+	"aria-multiselectable": true, "aria-orientation": true, "aria-owns": true, "aria-placeholder": true,
+	"aria-posinset": true, "aria-pressed": true, "aria-readonly": true, "aria-relevant": true,
+	"aria-required": true, "aria-roledescription": true, "aria-rowcount": true, "aria-rowindex": true,
+	"aria-rowindextext": true, "aria-rowspan": true, "aria-selected": true, "aria-setsize": true,
+	"aria-sort": true, "aria-valuemax": true, "aria-valuemin": true, "aria-valuenow": true,
+	"aria-valuetext": true,
+}
+
+// unlabeledInputs are the input types that need no label, buttons are named
+// by their value
+var unlabeledInputs = map[string]bool{
+	"hidden": true, "submit": true, "reset": true, "button": true, "image": true,
+}
+
+// namedElement is an open link or button whose accessible name is being read
+type namedElement struct {
+	alt string
+}
+
+// labeledControl is a form control waiting for the labels of the document
+type labeledControl struct {
+	id          string
+	description string
+	labeled     bool
+}
+
+// accessibilityCollector checks the markup of a page against a basic set of
+// WCAG success criteria
+type accessibilityCollector struct {
+	issues []AccessibilityIssue
+	// html is set once the <html> element was seen
+	html bool
+	lang string
+	// ids counts the elements per id, idOrder keeps the first occurrences
+	ids     map[string]int
+	idOrder []string
+	// labelFor are the ids <label for> elements point to
+	labelFor map[string]bool
+	// labels is the number of open <label> elements
+	labels   int
+	controls []*labeledControl
+	// named are the open links and buttons, innermost last
+	named []*namedElement
+	// table is the open table, nil for layout tables
+	table *bool
+}
+
+func newAccessibilityCollector() *accessibilityCollector {
+	return &accessibilityCollector{ids: map[string]int{}, labelFor: map[string]bool{}}
+}
+
+func (c *accessibilityCollector) add(code string, severity Severity, criterion, format string, args ...any) {
+	c.issues = append(c.issues, AccessibilityIssue{Code: code, Severity: severity, Criterion: criterion, Message: fmt.Sprintf(format, args...)})
+}
+
+// Token implements PageExtractor
+func (c *accessibilityCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt != html.DoctypeToken {
+		c.element(w, tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *accessibilityCollector) Result(r *FetchResult) {
+	r.Accessibility = c.result()
+}
+
+// element checks a start tag of the document
+func (c *accessibilityCollector) element(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+
+	if id := strings.TrimSpace(attrs["id"]); id != "" {
+		if c.ids[id] == 0 {
+			c.idOrder = append(c.idOrder, id)
+		}
+		c.ids[id]++
+	}
+	c.aria(tok, attrs)
+
+	switch tok.Data {
+	case "html":
+		if !c.html {
+			c.html = true
+			c.lang = strings.TrimSpace(attrs["lang"])
+		}
+	case "label":
+		c.labelTag(w, attrs)
+	case "input", "select", "textarea":
+		c.control(tok, attrs)
+	case "img":
+		c.imgTag(attrs)
+	case "a":
+		if _, ok := attrs["href"]; ok {
+			c.nameTag(w, attrs, "link", "no_link_name", wcagLinkPurpose)
+		}
+	case "button":
+		c.nameTag(w, attrs, "button", "no_button_name", wcagNameRoleValue)
+	case "table":
+		c.tableTag(w, attrs)
+	case "th":
+		if c.table != nil {
+			*c.table = true
+		}
+	}
+}
+
+// aria flags role attributes without any known role and aria-* attributes
+// unknown to WAI-ARIA
+func (c *accessibilityCollector) aria(tok html.Token, attrs map[string]string) {
+	if role, ok := attrs["role"]; ok {
+		if ariaRole(role) == "" {
+			c.add("invalid_aria_role", SeverityError, wcagNameRoleValue, "The <%s> has the invalid role %q", tok.Data, role)
+		}
+	}
+
+	for _, attr := range tok.Attr {
+		if strings.HasPrefix(attr.Key, "aria-") && !ariaAttributes[attr.Key] {
+			c.add("invalid_aria_attribute", SeverityWarning, wcagNameRoleValue, "The <%s> has the unknown attribute %s", tok.Data, attr.Key)
+		}
+	}
+}
+
+// labelTag tracks the controls a <label> labels, either through its for
+// attribute or by wrapping them
+func (c *accessibilityCollector) labelTag(w Walker, attrs map[string]string) {
+	if target := strings.TrimSpace(attrs["for"]); target != "" {
+		c.labelFor[target] = true
+		return
+	}
+
+	c.labels++
+	w.WhenClosedOnly(func() {
+		c.labels--
+	})
+}
+
+// control collects a form control that needs a label
+func (c *accessibilityCollector) control(tok html.Token, attrs map[string]string) {
+	description := tok.Data
+	if tok.Data == "input" {
+		typ := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if unlabeledInputs[typ] {
+			if typ == "button" && strings.TrimSpace(attrs["value"]) == "" && !hasAriaName(attrs) {
+				c.add("no_button_name", SeverityError, wcagNameRoleValue, "The input button has no value or label")
+			}
+			return
+		}
+		if typ == "" {
+			typ = "text"
+		}
+		description = typ + " input"
+	}
+
+	if name := strings.TrimSpace(attrs["name"]); name != "" {
+		description += fmt.Sprintf(" %q", name)
+	} else if id := strings.TrimSpace(attrs["id"]); id != "" {
+		description += " #" + id
+	}
+
+	c.controls = append(c.controls, &labeledControl{
+		id:          strings.TrimSpace(attrs["id"]),
+		description: description,
+		labeled:     c.labels > 0 || hasAriaName(attrs) || strings.TrimSpace(attrs["title"]) != "",
+	})
+}
+
+// imgTag flags images without alt attribute and adds the alt text to the
+// name of the link or button around them
+func (c *accessibilityCollector) imgTag(attrs map[string]string) {
+	alt, ok := attrs["alt"]
+	if n := len(c.named); n > 0 {
+		c.named[n-1].alt += " " + alt
+	}
+
+	if !ok && !hasAriaName(attrs) {
+		c.add("missing_alt", SeverityError, wcagNonTextContent, "The image %s has no alt attribute", strings.TrimSpace(attrs["src"]))
+	}
+}
+
+// nameTag flags a link or button without text, alt text, title or ARIA label
+func (c *accessibilityCollector) nameTag(w Walker, attrs map[string]string, what, code, criterion string) {
+	if hasAriaName(attrs) || strings.TrimSpace(attrs["title"]) != "" {
+		return
+	}
+
+	el := &namedElement{}
+	c.named = append(c.named, el)
+	n := len(c.named)
+
+	w.WhenClosed(func(text string) {
+		c.named = c.named[:n-1]
+		if collapseWS(text+" "+el.alt) != "" {
+			return
+		}
+
+		if what == "link" {
+			c.add(code, SeverityError, criterion, "The link to %s has no accessible name", strings.TrimSpace(attrs["href"]))
+		} else {
+			c.add(code, SeverityError, criterion, "A button has no accessible name")
+		}
+	})
+}
+
+// tableTag flags data tables without header cells. Tables with a
+// presentation role are layout tables.
+func (c *accessibilityCollector) tableTag(w Walker, attrs map[string]string) {
+	outer := c.table
+	role := ariaRole(attrs["role"])
+	if role == "presentation" || role == "none" {
+		c.table = nil
+		w.WhenClosedOnly(func() {
+			c.table = outer
+		})
+		return
+	}
+
+	headers := false
+	c.table = &headers
+	w.WhenClosedOnly(func() {
+		c.table = outer
+		if !headers {
+			c.add("table_without_headers", SeverityWarning, wcagInfoRelations, "A table has no header cells")
+		}
+	})
+}
+
+func hasAriaName(attrs map[string]string) bool {
+	return strings.TrimSpace(attrs["aria-label"]) != "" || strings.TrimSpace(attrs["aria-labelledby"]) != ""
+}
+
+// result returns the issues of the document, page level issues first
+func (c *accessibilityCollector) result() []AccessibilityIssue {
+	issues := []AccessibilityIssue{}
+	if c.lang == "" {
+		issues = append(issues, AccessibilityIssue{Code: "missing_lang", Severity: SeverityError, Criterion: wcagLanguage, Message: "The html element has no lang attribute"})
+	}
+
+	// WCAG 2.2 dropped 4.1.1 Parsing, duplicate ids still break the labels
+	// and ARIA references that point to them
+	for _, id := range c.idOrder {
+		if n := c.ids[id]; n > 1 {
+			issues = append(issues, AccessibilityIssue{Code: "duplicate_id", Severity: SeverityWarning, Criterion: wcagNameRoleValue, Message: fmt.Sprintf("The id %q is used by %d elements", id, n)})
+		}
+	}
+
+	for _, ctl := range c.controls {
+		if !ctl.labeled && !(ctl.id != "" && c.labelFor[ctl.id]) {
+			issues = append(issues, AccessibilityIssue{Code: "missing_label", Severity: SeverityError, Criterion: wcagInfoRelations, Message: fmt.Sprintf("The %s has no label", ctl.description)})
+		}
+	}
+
+	return append(issues, c.issues...)
+}
@@ -0,0 +1,50 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type attemptTimerKey struct{}
+
+// AttemptTimer records how long the last request sent through a context took
+// to respond. Unlike the time spent in Fetch or Ping it leaves out the
+// backoff between retries.
+type AttemptTimer struct {
+	mu   sync.Mutex
+	last time.Duration
+	ok   bool
+}
+
+// WithAttemptTimer returns a context whose requests are timed by the returned
+// AttemptTimer
+func WithAttemptTimer(ctx context.Context) (context.Context, *AttemptTimer) {
+	t := &AttemptTimer{}
+	return context.WithValue(ctx, attemptTimerKey{}, t), t
+}
+
+// Latency returns the duration of the last request, or fallback when no
+// request was timed
+func (t *AttemptTimer) Latency(fallback time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.ok {
+		return fallback
+	}
+
+	return t.last
+}
+
+// observeAttempt records a request started at start on the timer of ctx
+func observeAttempt(ctx context.Context, start time.Time) {
+	t, ok := ctx.Value(attemptTimerKey{}).(*AttemptTimer)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	t.last, t.ok = time.Since(start), true
+	t.mu.Unlock()
+}
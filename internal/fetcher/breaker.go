@@ -0,0 +1,150 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrHostUnavailable is returned without sending a request while the circuit
+// of a host is open
+var ErrHostUnavailable = errors.New("host unavailable")
+
+// circuit is the breaker state of a single host
+type circuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type breakerFetcher struct {
+	next      Fetcher
+	logger    *slog.Logger
+	threshold int
+	cooldown  time.Duration
+
+	mu    *sync.Mutex
+	hosts map[string]*circuit
+}
+
+// NewCircuitBreakerFetcher wraps a Fetcher with a circuit breaker per host.
+// After threshold consecutive failures of a host its circuit opens and
+// further requests fail immediately with ErrHostUnavailable. Once the
+// cooldown has passed a single probe request is let through, closing the
+// circuit again on success.
+func NewCircuitBreakerFetcher(f Fetcher, logger *slog.Logger, threshold int, cooldown time.Duration) Fetcher {
+	return breakerFetcher{
+		next:      f,
+		logger:    logger,
+		threshold: threshold,
+		cooldown:  cooldown,
+		mu:        &sync.Mutex{},
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+func (b breakerFetcher) Fetch(ctx context.Context, rawURL string, opts ...FetchOption) (*FetchResult, error) {
+	host := hostOf(rawURL)
+	if !b.allow(host) {
+		return nil, fmt.Errorf("could not reach to server: %w", ErrHostUnavailable)
+	}
+
+	result, err := b.next.Fetch(ctx, rawURL, opts...)
+	b.record(host, err)
+
+	return result, err
+}
+
+func (b breakerFetcher) Ping(ctx context.Context, rawURL string, opts ...PingOption) (*PingResult, error) {
+	host := hostOf(rawURL)
+	if !b.allow(host) {
+		return nil, ErrHostUnavailable
+	}
+
+	result, err := b.next.Ping(ctx, rawURL, opts...)
+	b.record(host, err)
+
+	return result, err
+}
+
+// allow reports whether a request to host may be sent
+func (b breakerFetcher) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok || c.failures < b.threshold {
+		return true
+	}
+
+	if c.probing || time.Since(c.openedAt) < b.cooldown {
+		return false
+	}
+
+	c.probing = true
+	return true
+}
+
+// record updates the circuit of host with the outcome of a request
+func (b breakerFetcher) record(host string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+
+	if ClassifyError(err) == ErrorClassCanceled {
+		c.probing = false
+		return
+	}
+
+	if !isHostFailure(err) {
+		if c.failures >= b.threshold {
+			b.logger.Info("Circuit closed", "host", host)
+		}
+		delete(b.hosts, host)
+		return
+	}
+
+	c.failures++
+	c.probing = false
+	if c.failures >= b.threshold {
+		if c.failures == b.threshold {
+			b.logger.Warn("Circuit opened", "host", host, "failures", c.failures)
+		}
+		c.openedAt = time.Now()
+	}
+}
+
+// isHostFailure reports whether err means the host itself is unhealthy, as
+// opposed to a single missing page
+func isHostFailure(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	switch ClassifyError(err) {
+	case ErrorClassDNS, ErrorClassTimeout, ErrorClassConnectionRefused, ErrorClassTLS, ErrorClassUnknown:
+		return true
+	}
+
+	return false
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Host)
+}
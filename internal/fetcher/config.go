@@ -0,0 +1,35 @@
+package fetcher
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+// NewFetcherFromConfig creates the Fetcher described by config, retrying
+// transient failures and guarding failing hosts with a circuit breaker when
+// enabled
+func NewFetcherFromConfig(hc *http.Client, logger *slog.Logger, config *util.CrawlerConfig) Fetcher {
+	f := NewFetcher(hc, logger, config.BodySizeLimit,
+		WithMaxRedirects(config.MaxRedirects),
+		WithLongRedirectChain(config.LongRedirectChain),
+		WithUserAgent(config.UserAgent),
+	)
+
+	if config.RetryMaxAttempts > 1 {
+		f = NewRetryFetcher(f, logger, RetryPolicy{
+			MaxAttempts:     config.RetryMaxAttempts,
+			BaseDelay:       config.RetryBaseDelay,
+			MaxDelay:        config.RetryMaxDelay,
+			Jitter:          config.RetryJitter,
+			RetryableStatus: config.RetryStatusCodes,
+		})
+	}
+
+	if config.BreakerThreshold > 0 {
+		f = NewCircuitBreakerFetcher(f, logger, config.BreakerThreshold, config.BreakerCooldown)
+	}
+
+	return f
+}
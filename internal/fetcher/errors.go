@@ -0,0 +1,102 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass groups request errors by their cause
+type ErrorClass string
+
+const (
+	ErrorClassNone              ErrorClass = ""
+	ErrorClassInvalidURL        ErrorClass = "invalid_url"
+	ErrorClassInvalidRequest    ErrorClass = "invalid_request"
+	ErrorClassDNS               ErrorClass = "dns"
+	ErrorClassTLS               ErrorClass = "tls"
+	ErrorClassTimeout           ErrorClass = "timeout"
+	ErrorClassConnectionRefused ErrorClass = "connection_refused"
+	ErrorClassHTTPStatus        ErrorClass = "http_status"
+	ErrorClassRedirect          ErrorClass = "redirect"
+	ErrorClassHostUnavailable   ErrorClass = "host_unavailable"
+	ErrorClassCanceled          ErrorClass = "canceled"
+	ErrorClassUnknown           ErrorClass = "unknown"
+)
+
+// ClassifyError returns the class of an error returned by Fetch or Ping
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	// A fetch the fetcher cannot serve fails the same way on every attempt
+	// and for every host
+	if errors.Is(err, ErrUnknownExtractor) {
+		return ErrorClassInvalidRequest
+	}
+
+	if errors.Is(err, ErrBadStatus) {
+		return ErrorClassHTTPStatus
+	}
+
+	if errors.Is(err, ErrHostUnavailable) {
+		return ErrorClassHostUnavailable
+	}
+
+	if errors.Is(err, ErrRedirectLoop) || errors.Is(err, ErrTooManyRedirects) {
+		return ErrorClassRedirect
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS
+	}
+
+	if isTLSError(err) {
+		return ErrorClassTLS
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassConnectionRefused
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorClassTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Op == "parse" {
+		return ErrorClassInvalidURL
+	}
+
+	return ErrorClassUnknown
+}
+
+func isTLSError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		recordErr       tls.RecordHeaderError
+		authorityErr    x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		invalidErr      x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.As(err, &verificationErr), errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return true
+	}
+
+	return strings.Contains(err.Error(), "tls: ")
+}
@@ -0,0 +1,180 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// ErrUnknownExtractor is returned when a fetch enables an extractor the
+// registry does not know
+var ErrUnknownExtractor = errors.New("unknown extractor")
+
+// Names of the built-in extractors
+const (
+	ExtractorHTMLVersion    = "html-version"
+	ExtractorTitle          = "title"
+	ExtractorHeadings       = "headings"
+	ExtractorAnchors        = "anchors"
+	ExtractorMeta           = "meta"
+	ExtractorStructuredData = "structured-data"
+	ExtractorForms          = "forms"
+	ExtractorImages         = "images"
+	ExtractorResources      = "resources"
+	ExtractorAccessibility  = "accessibility"
+)
+
+// Extractor reads pages while they are streamed and contributes to their
+// FetchResult. An extractor is shared by concurrent fetches, the state of a
+// single page lives in the PageExtractor it creates.
+type Extractor interface {
+	// Name identifies the extractor in the registry and keys its entry in
+	// FetchResult.Extracted
+	Name() string
+	// NewPage is called once for every fetched page
+	NewPage(page *Page) PageExtractor
+}
+
+// PageExtractor extracts from a single page
+type PageExtractor interface {
+	// Token is called for the doctype and every start tag of the document.
+	// The walker tells about the end of the element of a start tag.
+	Token(w Walker, tt html.TokenType, tok html.Token)
+	// Result is called once the whole document was read and stores what was
+	// extracted in the result of the page. Custom extractors store their
+	// result in r.Extracted under their name.
+	Result(r *FetchResult)
+}
+
+// Page is the page an extractor reads
+type Page struct {
+	// URL is the URL of the page after redirects
+	URL string
+	doc *documentURL
+}
+
+// Resolve resolves a reference of the page against its base URL. The base
+// URL may be declared anywhere in the document, so references are only
+// resolved reliably in Result.
+func (p *Page) Resolve(ref string) string {
+	return p.doc.resolve(ref)
+}
+
+type extractorFunc struct {
+	name    string
+	newPage func(page *Page) PageExtractor
+}
+
+// NewExtractor creates an Extractor from its name and the function creating
+// its PageExtractor
+func NewExtractor(name string, newPage func(page *Page) PageExtractor) Extractor {
+	return extractorFunc{name: name, newPage: newPage}
+}
+
+func (e extractorFunc) Name() string {
+	return e.name
+}
+
+func (e extractorFunc) NewPage(page *Page) PageExtractor {
+	return e.newPage(page)
+}
+
+// Registry holds the extractors a fetcher can run, in the order they receive
+// tokens and store their results. Extractors are registered while setting up
+// the fetcher, Register must not be called while fetching.
+type Registry struct {
+	extractors []Extractor
+}
+
+// NewRegistry creates a registry with the given extractors
+func NewRegistry(extractors ...Extractor) *Registry {
+	r := &Registry{}
+	for _, e := range extractors {
+		r.Register(e)
+	}
+
+	return r
+}
+
+// DefaultRegistry creates a registry with the built-in extractors
+func DefaultRegistry() *Registry {
+	return NewRegistry(
+		NewExtractor(ExtractorHTMLVersion, func(*Page) PageExtractor { return &versionExtractor{} }),
+		NewExtractor(ExtractorTitle, func(*Page) PageExtractor { return &titleExtractor{} }),
+		NewExtractor(ExtractorHeadings, func(*Page) PageExtractor { return &headingCollector{} }),
+		NewExtractor(ExtractorAnchors, func(p *Page) PageExtractor { return &anchorCollector{doc: p.doc} }),
+		NewExtractor(ExtractorMeta, func(p *Page) PageExtractor { return newMetaCollector(p.doc) }),
+		NewExtractor(ExtractorStructuredData, func(*Page) PageExtractor { return newStructuredDataCollector() }),
+		NewExtractor(ExtractorForms, func(p *Page) PageExtractor { return newFormCollector(p.doc) }),
+		NewExtractor(ExtractorImages, func(p *Page) PageExtractor { return newImageCollector(p.doc) }),
+		NewExtractor(ExtractorResources, func(p *Page) PageExtractor { return newResourceCollector(p.doc) }),
+		NewExtractor(ExtractorAccessibility, func(*Page) PageExtractor { return newAccessibilityCollector() }),
+	)
+}
+
+// Register adds an extractor. It panics when an extractor with the same name
+// is registered already.
+func (r *Registry) Register(e Extractor) {
+	for _, registered := range r.extractors {
+		if registered.Name() == e.Name() {
+			panic(fmt.Sprintf("fetcher: extractor %q registered twice", e.Name()))
+		}
+	}
+
+	r.extractors = append(r.extractors, e)
+}
+
+// Names returns the names of the registered extractors
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.extractors))
+	for _, e := range r.extractors {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
+
+// enabled returns the named extractors in registration order, every
+// extractor without names
+func (r *Registry) enabled(names []string) ([]Extractor, error) {
+	if len(names) == 0 {
+		return r.extractors, nil
+	}
+
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+
+	var extractors []Extractor
+	for _, e := range r.extractors {
+		if want[e.Name()] {
+			extractors = append(extractors, e)
+			delete(want, e.Name())
+		}
+	}
+
+	for _, name := range names {
+		if want[name] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownExtractor, name)
+		}
+	}
+
+	return extractors, nil
+}
+
+// FetchOption is a function that configures a single fetch
+type FetchOption func(*fetchConfig)
+
+type fetchConfig struct {
+	extractors []string
+}
+
+// WithExtractors limits a fetch to the named extractors of the registry. The
+// fields of FetchResult filled by other extractors stay empty.
+func WithExtractors(names ...string) FetchOption {
+	return func(c *fetchConfig) {
+		c.extractors = names
+	}
+}
@@ -1,19 +1,41 @@
 package fetcher
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 )
 
 var ErrBadStatus = errors.New("bad status code")
 
+// StatusError carries the status code of a non 2xx response and unwraps to
+// ErrBadStatus. RetryAfter holds the delay requested by the server, if any.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *StatusError) Error() string {
+	return ErrBadStatus.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return ErrBadStatus
+}
+
 type limitedBody struct {
 	io.Reader
 	closer io.Closer
@@ -23,22 +45,33 @@ func (l limitedBody) Close() error {
 	return l.closer.Close()
 }
 
-func fetch(ctx context.Context, httpClient *http.Client, rawUrl string, bodySizeLimit int64) (io.ReadCloser, error) {
+// setUserAgent identifies the crawler, Go's default user-agent is kept when
+// none is configured
+func setUserAgent(req *http.Request, userAgent string) {
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
+
+func fetch(ctx context.Context, httpClient *http.Client, rawUrl string, bodySizeLimit int64, userAgent string) (io.ReadCloser, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
 	if err != nil {
 		return nil, err
 	}
+	setUserAgent(req, userAgent)
 
+	start := time.Now()
 	resp, err := httpClient.Do(req)
+	observeAttempt(ctx, start)
 	if err != nil {
-		return nil, fmt.Errorf("could not reach to server")
+		return nil, fmt.Errorf("could not reach to server: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 8<<10))
 		_ = resp.Body.Close()
 
-		return nil, fmt.Errorf("server respond bad status code %v: %w", resp.StatusCode, ErrBadStatus)
+		return nil, fmt.Errorf("server respond bad status code %v: %w", resp.StatusCode, newStatusError(resp))
 	}
 
 	return limitedBody{
@@ -47,116 +80,203 @@ func fetch(ctx context.Context, httpClient *http.Client, rawUrl string, bodySize
 	}, nil
 }
 
-func streamToken(reader io.Reader, handler func(z *html.Tokenizer, tokenType html.TokenType, tok html.Token) error) error {
-	z := html.NewTokenizer(reader)
-
-	for {
-		tt := z.Next()
-		zn := z.Token()
+// element is an open element of the document
+type element struct {
+	tag string
+	// text collects the text content while a handler waits for it
+	text    *strings.Builder
+	onClose []func(text string)
+}
 
-		if tt == html.ErrorToken {
-			if z.Err() == io.EOF {
-				return nil
-			}
-		}
+// tokenWalker tracks the open elements of a streamed document, so handlers
+// can receive the text content of an element once it is closed without
+// consuming the tokens other handlers rely on
+type tokenWalker struct {
+	open []*element
+	// current is the element of the start tag being handled
+	current *element
+}
 
-		switch tt {
-		case html.DoctypeToken, html.StartTagToken, html.SelfClosingTagToken:
-			if err := handler(z, tt, zn); err != nil {
-				return err
-			}
-		}
-	}
+// Walker lets extractors wait for the end of the element of the start tag
+// they are handling
+type Walker interface {
+	// WhenClosed calls fn with the whitespace collapsed text content of the
+	// element once it is closed. Void and self closing elements are closed
+	// right after their start tag.
+	WhenClosed(fn func(text string))
+	// WhenClosedOnly calls fn once the element is closed, without collecting
+	// its text content
+	WhenClosedOnly(fn func())
 }
 
-func extractAnchor(tok html.Token) (Anchor, bool) {
-	attr, ok := findAttr(tok, "href")
+// WhenClosed implements Walker
+func (w *tokenWalker) WhenClosed(fn func(text string)) {
+	el := w.current
+	if el == nil {
+		return
+	}
 
-	if !ok {
-		return Anchor{}, false
+	if el.text == nil {
+		el.text = &strings.Builder{}
 	}
+	el.onClose = append(el.onClose, fn)
+}
 
-	if attr.Val == "#" || attr.Val == "javascript::" || attr.Val == "/" {
-		return Anchor{}, false
+// WhenClosedOnly implements Walker
+func (w *tokenWalker) WhenClosedOnly(fn func()) {
+	if el := w.current; el != nil {
+		el.onClose = append(el.onClose, func(string) { fn() })
 	}
+}
 
-	urlStr := attr.Val
-	isUrlInternal := strings.HasPrefix(urlStr, "http://") || strings.HasPrefix(urlStr, "https://")
+// voidElements never have content or an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
 
-	return Anchor{
-		URL:      urlStr,
-		External: isUrlInternal,
-	}, true
+// autoClosed elements are implicitly closed by a start tag of the same name,
+// like the previous item of a list
+var autoClosed = map[string]bool{
+	"p": true, "li": true, "option": true, "tr": true, "td": true, "th": true, "dt": true, "dd": true,
 }
 
-func extractHTMLVersion(token html.Token) string {
-	doctype := strings.ToLower(token.Data)
+// rawTextElements hold text that is not part of the content of their parents
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "template": true, "noscript": true,
+}
 
-	if strings.Contains(doctype, "html") {
-		if strings.Contains(doctype, "4.01") {
-			return "HTML 4.01"
-		} else if strings.Contains(doctype, "xhtml") {
-			return "XHTML"
-		} else {
-			return "HTML5" // <!DOCTYPE html>
-		}
+func (w *tokenWalker) start(tag string) *element {
+	if n := len(w.open); n > 0 && autoClosed[tag] && w.open[n-1].tag == tag {
+		w.close(tag)
 	}
 
-	return "Unknown"
-}
+	if tag == "br" {
+		w.text(" ")
+	}
 
-func extractHeaders(z *html.Tokenizer, tok html.Token, hm map[string][]string) {
-	tv, _ := readTextValue(z)
+	w.current = &element{tag: tag}
 
-	titles, ok := hm[tok.Data]
+	return w.current
+}
 
-	if !ok {
-		hm[tok.Data] = []string{tv}
+func (w *tokenWalker) text(text string) {
+	n := len(w.open)
+	if n > 0 && rawTextElements[w.open[n-1].tag] {
+		if el := w.open[n-1]; el.text != nil {
+			el.text.WriteString(text)
+		}
+		return
 	}
 
-	hm[tok.Data] = append(titles, tv)
+	for _, el := range w.open {
+		if el.text != nil {
+			el.text.WriteString(text)
+		}
+	}
 }
 
-func findAttr(token html.Token, key string) (html.Attribute, bool) {
-	for _, attr := range token.Attr {
-		if attr.Key == key {
-			return attr, true
+// close closes the innermost open element with the given tag and every
+// element opened after it. End tags without open element are ignored.
+func (w *tokenWalker) close(tag string) {
+	for i := len(w.open) - 1; i >= 0; i-- {
+		if w.open[i].tag != tag {
+			continue
+		}
+
+		for j := len(w.open) - 1; j >= i; j-- {
+			w.finish(w.open[j])
 		}
+		w.open = w.open[:i]
+		return
 	}
+}
 
-	return html.Attribute{}, false
+func (w *tokenWalker) finish(el *element) {
+	text := ""
+	if el.text != nil {
+		text = collapseWS(el.text.String())
+	}
+
+	for _, fn := range el.onClose {
+		fn(text)
+	}
 }
 
-func readTextValue(z *html.Tokenizer) (string, error) {
-	var (
-		depth = 1
-		buf   bytes.Buffer
-	)
+// streamToken passes the doctype and every start tag of the document to the
+// handler. Elements still open at the end of the document are closed.
+func streamToken(reader io.Reader, handler func(w Walker, tokenType html.TokenType, tok html.Token) error) error {
+	z := html.NewTokenizer(reader)
+	w := &tokenWalker{}
 
 	for {
-		switch z.Next() {
+		tt := z.Next()
+
+		switch tt {
 		case html.ErrorToken:
-			return strings.TrimSpace(buf.String()), z.Err()
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+
+			for i := len(w.open) - 1; i >= 0; i-- {
+				w.finish(w.open[i])
+			}
+			return nil
 
 		case html.TextToken:
-			buf.Write(z.Text())
+			w.text(string(z.Text()))
+
+		case html.DoctypeToken:
+			if err := handler(w, tt, z.Token()); err != nil {
+				return err
+			}
 
 		case html.StartTagToken, html.SelfClosingTagToken:
-			depth++
+			tok := z.Token()
+			el := w.start(tok.Data)
+
+			if err := handler(w, tt, tok); err != nil {
+				return err
+			}
+			w.current = nil
 
-			t := z.Token()
-			if t.Data == "br" {
-				buf.WriteByte(' ')
+			if tt == html.SelfClosingTagToken || voidElements[tok.Data] {
+				w.finish(el)
+			} else {
+				w.open = append(w.open, el)
 			}
 
 		case html.EndTagToken:
-			depth--
+			name, _ := z.TagName()
+			w.close(string(name))
+		}
+	}
+}
 
-			if depth == 0 {
-				return collapseWS(buf.String()), nil
-			}
+func extractHTMLVersion(token html.Token) string {
+	doctype := strings.ToLower(token.Data)
+
+	if strings.Contains(doctype, "html") {
+		if strings.Contains(doctype, "4.01") {
+			return "HTML 4.01"
+		} else if strings.Contains(doctype, "xhtml") {
+			return "XHTML"
+		} else {
+			return "HTML5" // <!DOCTYPE html>
 		}
 	}
+
+	return "Unknown"
+}
+
+func findAttr(token html.Token, key string) (html.Attribute, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr, true
+		}
+	}
+
+	return html.Attribute{}, false
 }
 
 func collapseWS(s string) string {
@@ -2,8 +2,6 @@ package fetcher
 
 import (
 	"context"
-	"errors"
-	"io"
 	"log/slog"
 	"net/http"
 
@@ -11,46 +9,96 @@ import (
 )
 
 type Fetcher interface {
-	Fetch(ctx context.Context, url string) (*FetchResult, error)
-	Ping(ctx context.Context, url string) error
+	Fetch(ctx context.Context, url string, opts ...FetchOption) (*FetchResult, error)
+	Ping(ctx context.Context, url string, opts ...PingOption) (*PingResult, error)
 }
 
 type fetcher struct {
 	httpClient    *http.Client
 	bodySizeLimit int64
 	logger        *slog.Logger
+	userAgent     string
+
+	maxRedirects  int
+	longRedirects int
+
+	registry *Registry
 }
 
-func (f fetcher) Ping(ctx context.Context, url string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return err
+// FetcherOption is a function that configures the fetcher
+type FetcherOption func(*fetcher)
+
+// WithMaxRedirects sets how many redirects are followed before a request fails
+func WithMaxRedirects(n int) FetcherOption {
+	return func(f *fetcher) {
+		f.maxRedirects = n
 	}
+}
 
-	resp, err := f.httpClient.Do(req)
-	if err != nil {
-		return err
+// WithLongRedirectChain sets the number of hops above which a redirect chain
+// is flagged as long
+func WithLongRedirectChain(n int) FetcherOption {
+	return func(f *fetcher) {
+		f.longRedirects = n
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return errors.New("unexpected status code: " + resp.Status)
+// WithUserAgent sets the User-Agent header of page fetches and pings. It
+// should match the user-agent robots.txt rules are applied for.
+func WithUserAgent(userAgent string) FetcherOption {
+	return func(f *fetcher) {
+		f.userAgent = userAgent
 	}
+}
 
-	return nil
+// WithRegistry sets the extractors run on every fetched page, the built-in
+// extractors by default
+func WithRegistry(registry *Registry) FetcherOption {
+	return func(f *fetcher) {
+		f.registry = registry
+	}
 }
 
-func NewFetcher(httpClient *http.Client, logger *slog.Logger, bodySizeLimit int64) Fetcher {
-	return fetcher{httpClient: httpClient, logger: logger, bodySizeLimit: bodySizeLimit}
+// NewFetcher creates a Fetcher. The redirect policy of the given client is
+// replaced by one that records the redirect chain of every request.
+func NewFetcher(httpClient *http.Client, logger *slog.Logger, bodySizeLimit int64, opts ...FetcherOption) Fetcher {
+	f := fetcher{
+		logger:        logger,
+		bodySizeLimit: bodySizeLimit,
+		maxRedirects:  defaultMaxRedirects,
+		longRedirects: defaultLongRedirects,
+		registry:      DefaultRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(&f)
+	}
+
+	hc := *httpClient
+	hc.CheckRedirect = f.checkRedirect
+	f.httpClient = &hc
+
+	return f
 }
 
 // Fetch
 // Fetches the given url and returns a structured response
 // if error returned it might be the reason the given url is not reachable
-func (f fetcher) Fetch(ctx context.Context, url string) (*FetchResult, error) {
+func (f fetcher) Fetch(ctx context.Context, url string, opts ...FetchOption) (*FetchResult, error) {
+	config := fetchConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	extractors, err := f.registry.enabled(config.extractors)
+	if err != nil {
+		return nil, err
+	}
+
 	f.logger.Info("Starting fetch", "url", url)
 
-	resp, err := fetch(ctx, f.httpClient, url, f.bodySizeLimit)
+	ctx, rec := withRedirectRecorder(ctx)
+	resp, err := fetch(ctx, f.httpClient, url, f.bodySizeLimit, f.userAgent)
 	if err != nil {
 		f.logger.Error("Failed to fetch URL", "url", url, "error", err.Error())
 		return nil, err
@@ -62,45 +110,25 @@ func (f fetcher) Fetch(ctx context.Context, url string) (*FetchResult, error) {
 
 	r := &FetchResult{}
 	r.URL = url
-	r.HeaderMap = make(map[string][]string)
-
-	anchorMap := map[string]struct{}{}
-
-	err = streamToken(resp, func(z *html.Tokenizer, tt html.TokenType, tok html.Token) error {
-		if tt == html.ErrorToken {
-			if z.Err() == io.EOF {
-				return nil
-			}
+	r.Redirects = rec.chain(f.longRedirects)
 
-			return z.Err()
-		}
-
-		tag := tok.Data
-
-		if tt == html.DoctypeToken {
-			r.HTMLVersion = extractHTMLVersion(tok)
-		}
+	page := &Page{URL: r.FinalURL()}
+	page.doc = newDocumentURL(page.URL)
 
-		switch tag {
-		case "a":
-			a, ok := extractAnchor(tok)
+	pages := make([]PageExtractor, 0, len(extractors))
+	for _, e := range extractors {
+		pages = append(pages, e.NewPage(page))
+	}
 
-			if !ok {
-				break
+	err = streamToken(resp, func(w Walker, tt html.TokenType, tok html.Token) error {
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			if tok.Data == "base" {
+				page.doc.baseTag(tok)
 			}
+		}
 
-			if _, ok := anchorMap[a.URL]; !ok {
-				anchorMap[a.URL] = struct{}{}
-				r.Anchors = append(r.Anchors, a)
-			}
-		case "h1", "h2", "h3", "h4", "h5", "h6":
-			extractHeaders(z, tok, r.HeaderMap)
-		case "title":
-			r.Title, _ = readTextValue(z)
-		case "input":
-			if attr, ok := findAttr(tok, "type"); ok && attr.Val == "password" {
-				r.HasLoginForm = true
-			}
+		for _, p := range pages {
+			p.Token(w, tt, tok)
 		}
 
 		return nil
@@ -111,32 +139,76 @@ func (f fetcher) Fetch(ctx context.Context, url string) (*FetchResult, error) {
 		return nil, err
 	}
 
+	if base := page.doc.baseURL(); base != nil {
+		r.BaseURL = base.String()
+	}
+	r.Extracted = map[string]any{}
+	for _, p := range pages {
+		p.Result(r)
+	}
+
 	f.logger.Info("Fetch completed successfully", "url", url, "title", r.Title, "anchors_found", len(r.Anchors), "has_login_form", r.HasLoginForm)
 
 	return r, nil
 }
 
-type FormElement struct {
-	Name string
-	Type string
+type FetchResult struct {
+	URL           string               `json:"url"`
+	BaseURL       string               `json:"base_url"`
+	Title         string               `json:"title"`
+	HeaderMap     map[string][]string  `json:"headers"`
+	Outline       Outline              `json:"outline"`
+	Accessibility []AccessibilityIssue `json:"accessibility"`
+	// Extracted holds the results of custom extractors keyed by their name
+	Extracted      map[string]any `json:"extracted,omitempty"`
+	Anchors        []Anchor       `json:"anchors"`
+	HasLoginForm   bool           `json:"has_login_form"`
+	Forms          []Form         `json:"forms"`
+	Images         []Image        `json:"images"`
+	Resources      []Resource     `json:"resources"`
+	HTMLVersion    string         `json:"html_version"`
+	Redirects      RedirectChain  `json:"redirects"`
+	Meta           Meta           `json:"meta"`
+	StructuredData StructuredData `json:"structured_data"`
 }
 
-type Anchor struct {
-	External bool
-	URL      string
+// FinalURL returns the URL of the page after redirects
+func (r *FetchResult) FinalURL() string {
+	return r.Redirects.finalURL(r.URL)
 }
 
-type Form struct {
-	Elements []FormElement
-	Action   string
-	Method   string
+// versionExtractor detects the HTML version from the doctype
+type versionExtractor struct {
+	version string
 }
 
-type FetchResult struct {
-	URL          string
-	Title        string
-	HeaderMap    map[string][]string
-	Anchors      []Anchor
-	HasLoginForm bool
-	HTMLVersion  string
+// Token implements PageExtractor
+func (e *versionExtractor) Token(_ Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		e.version = extractHTMLVersion(tok)
+	}
+}
+
+// Result implements PageExtractor
+func (e *versionExtractor) Result(r *FetchResult) {
+	r.HTMLVersion = e.version
+}
+
+// titleExtractor reads the text of the last <title> of a page
+type titleExtractor struct {
+	title string
+}
+
+// Token implements PageExtractor
+func (e *titleExtractor) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt != html.DoctypeToken && tok.Data == "title" {
+		w.WhenClosed(func(text string) {
+			e.title = text
+		})
+	}
+}
+
+// Result implements PageExtractor
+func (e *titleExtractor) Result(r *FetchResult) {
+	r.Title = e.title
 }
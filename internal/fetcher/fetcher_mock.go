@@ -7,12 +7,15 @@ import (
 
 type fakeFetcher map[string]*FetchResult
 
-func (f fakeFetcher) Ping(ctx context.Context, url string) (err error) {
-	_, err = f.Fetch(ctx, url)
-	return
+func (f fakeFetcher) Ping(ctx context.Context, url string, _ ...PingOption) (*PingResult, error) {
+	if _, err := f.Fetch(ctx, url); err != nil {
+		return nil, err
+	}
+
+	return &PingResult{Method: "HEAD", StatusCode: 200, FinalURL: url}, nil
 }
 
-func (f fakeFetcher) Fetch(ctx context.Context, url string) (*FetchResult, error) {
+func (f fakeFetcher) Fetch(ctx context.Context, url string, _ ...FetchOption) (*FetchResult, error) {
 	if res, ok := f[url]; ok {
 		return res, nil
 	}
@@ -31,3 +34,8 @@ func NewFakeFetcher() Fetcher {
 		},
 	}
 }
+
+// NewFakeFetcherFromMap creates a fake Fetcher serving the given pages keyed by URL
+func NewFakeFetcherFromMap(pages map[string]*FetchResult) Fetcher {
+	return fakeFetcher(pages)
+}
@@ -2,14 +2,18 @@ package fetcher
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
 )
 
 func TestFetch(t *testing.T) {
@@ -58,3 +62,1119 @@ func TestFetch(t *testing.T) {
 	assert.Equal(t, true, result.HasLoginForm)
 	assert.Equal(t, "HTML5", result.HTMLVersion)
 }
+
+func TestPing_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	res, err := f.Ping(context.Background(), server.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.ErrorIs(t, err, ErrBadStatus)
+
+	_, err = f.Fetch(context.Background(), server.URL)
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "server respond bad status code 503: bad status code", err.Error())
+}
+
+func TestPing_Redirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	res, err := f.Ping(context.Background(), server.URL+"/old")
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, server.URL+"/new", res.FinalURL)
+	assert.Equal(t, []RedirectHop{
+		{URL: server.URL + "/old", StatusCode: http.StatusMovedPermanently, Location: "/moved"},
+		{URL: server.URL + "/moved", StatusCode: http.StatusFound, Location: "/new"},
+	}, res.Redirects.Hops)
+	assert.False(t, res.Redirects.Loop)
+	assert.False(t, res.Redirects.Long)
+	assert.False(t, res.Redirects.Downgrade)
+}
+
+func TestPing_RedirectLoop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/a", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20, WithLongRedirectChain(1))
+
+	res, err := f.Ping(context.Background(), server.URL+"/a")
+
+	assert.ErrorIs(t, err, ErrRedirectLoop)
+	assert.Equal(t, ErrorClassRedirect, ClassifyError(err))
+	assert.Len(t, res.Redirects.Hops, 2)
+	assert.True(t, res.Redirects.Loop)
+	assert.True(t, res.Redirects.Long)
+}
+
+func TestPing_TooManyRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20, WithMaxRedirects(3))
+
+	res, err := f.Ping(context.Background(), server.URL+"/")
+
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+	assert.Len(t, res.Redirects.Hops, 3)
+	assert.False(t, res.Redirects.Loop)
+}
+
+func TestFetch_RedirectDowngrade(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<title>Plain</title>"))
+	}))
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/landing", http.StatusMovedPermanently)
+	}))
+	defer secure.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(secure.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), secure.URL)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Plain", result.Title)
+	assert.Len(t, result.Redirects.Hops, 1)
+	assert.True(t, result.Redirects.Downgrade)
+}
+
+func TestClassifyError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := server.URL
+	server.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	f := NewFetcher(&http.Client{Timeout: 5 * time.Second}, logger, 10<<20)
+
+	_, err := f.Ping(context.Background(), closedURL)
+	assert.Equal(t, ErrorClassConnectionRefused, ClassifyError(err))
+
+	_, err = f.Ping(context.Background(), tlsServer.URL)
+	assert.Equal(t, ErrorClassTLS, ClassifyError(err))
+
+	_, err = f.Ping(context.Background(), "http://host.invalid")
+	assert.Equal(t, ErrorClassDNS, ClassifyError(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.Ping(ctx, tlsServer.URL)
+	assert.Equal(t, ErrorClassCanceled, ClassifyError(err))
+
+	assert.Equal(t, ErrorClassHTTPStatus, ClassifyError(&StatusError{StatusCode: http.StatusNotFound}))
+	assert.Equal(t, ErrorClassTimeout, ClassifyError(context.DeadlineExceeded))
+	assert.Equal(t, ErrorClassNone, ClassifyError(nil))
+}
+
+func TestFetcher_UserAgent(t *testing.T) {
+	var agents []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Method+" "+r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20, WithUserAgent("AuditBot/1.0"))
+
+	_, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	_, err = f.Ping(context.Background(), server.URL)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"GET AuditBot/1.0", "HEAD AuditBot/1.0"}, agents)
+}
+
+func TestPing_HeadFallback(t *testing.T) {
+	var methods []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+
+		if r.URL.Path == "/no-head" && r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("large asset"))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+	ctx := context.Background()
+
+	res, err := f.Ping(ctx, server.URL+"/asset")
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodHead, res.Method)
+
+	res, err = f.Ping(ctx, server.URL+"/no-head")
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodGet, res.Method)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// A 404 on HEAD is trusted
+	res, err = f.Ping(ctx, server.URL+"/missing")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	assert.Equal(t, http.MethodHead, res.Method)
+
+	res, err = f.Ping(ctx, server.URL+"/asset", WithPingStrategy(PingStrategyGet))
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodGet, res.Method)
+
+	assert.Equal(t, []string{
+		"HEAD /asset",
+		"HEAD /no-head", "GET /no-head",
+		"HEAD /missing",
+		"GET /asset",
+	}, methods)
+}
+
+func TestRetryFetcher(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("<title>Recovered</title>"))
+		case "/throttled":
+			attempts.Add(1)
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			attempts.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = 10 * time.Millisecond
+	f := NewRetryFetcher(NewFetcher(server.Client(), logger, 10<<20), logger, policy)
+	ctx := context.Background()
+
+	result, err := f.Fetch(ctx, server.URL+"/flaky")
+	assert.Nil(t, err)
+	assert.Equal(t, "Recovered", result.Title)
+	assert.Equal(t, int32(3), attempts.Load())
+
+	// Client errors are permanent
+	attempts.Store(0)
+	_, err = f.Ping(ctx, server.URL+"/missing")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	assert.Equal(t, int32(1), attempts.Load())
+
+	// A Retry-After beyond the maximum delay is not cut short
+	attempts.Store(0)
+	_, err = f.Ping(ctx, server.URL+"/throttled")
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, 120*time.Second, statusErr.RetryAfter)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestRetryFetcher_AttemptTimer(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = 200 * time.Millisecond
+	policy.Jitter = 0
+	f := NewRetryFetcher(NewFetcher(server.Client(), logger, 10<<20), logger, policy)
+
+	ctx, timer := WithAttemptTimer(context.Background())
+	assert.Equal(t, time.Hour, timer.Latency(time.Hour))
+
+	start := time.Now()
+	_, err := f.Ping(ctx, server.URL)
+	assert.Nil(t, err)
+
+	// The backoff between the attempts is not part of the latency
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Less(t, timer.Latency(time.Hour), 200*time.Millisecond)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Zero(t, parseRetryAfter(""))
+	assert.Zero(t, parseRetryAfter("soon"))
+
+	at := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, time.Minute, parseRetryAfter(at), float64(2*time.Second))
+}
+
+type countingFetcher struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *countingFetcher) Fetch(context.Context, string, ...FetchOption) (*FetchResult, error) {
+	c.calls.Add(1)
+	return &FetchResult{}, c.err
+}
+
+func (c *countingFetcher) Ping(context.Context, string, ...PingOption) (*PingResult, error) {
+	c.calls.Add(1)
+	return &PingResult{}, c.err
+}
+
+func TestCircuitBreakerFetcher(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	next := &countingFetcher{err: context.DeadlineExceeded}
+	f := NewCircuitBreakerFetcher(next, logger, 2, 20*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, _ = f.Ping(ctx, "https://down.test/page")
+	}
+
+	// The circuit opens after two failures, the other hosts are not affected
+	assert.Equal(t, int32(2), next.calls.Load())
+	_, err := f.Ping(ctx, "https://down.test/other")
+	assert.ErrorIs(t, err, ErrHostUnavailable)
+	assert.Equal(t, ErrorClassHostUnavailable, ClassifyError(err))
+	_, err = f.Fetch(ctx, "https://down.test")
+	assert.ErrorIs(t, err, ErrHostUnavailable)
+
+	_, _ = f.Ping(ctx, "https://up.test/page")
+	assert.Equal(t, int32(3), next.calls.Load())
+
+	// After the cooldown a single probe is let through
+	time.Sleep(30 * time.Millisecond)
+	next.err = nil
+	_, err = f.Ping(ctx, "https://down.test/page")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), next.calls.Load())
+
+	_, err = f.Ping(ctx, "https://down.test/page")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(5), next.calls.Load())
+}
+
+func TestCircuitBreakerFetcher_IgnoresMissingPages(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	next := &countingFetcher{err: &StatusError{StatusCode: http.StatusNotFound}}
+	f := NewCircuitBreakerFetcher(next, logger, 2, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		_, _ = f.Ping(context.Background(), "https://shop.test/missing")
+	}
+
+	assert.Equal(t, int32(5), next.calls.Load())
+}
+
+func TestFetcherDecorators_UnknownExtractor(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	next := &countingFetcher{err: fmt.Errorf("%w: ads", ErrUnknownExtractor)}
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	f := NewCircuitBreakerFetcher(NewRetryFetcher(next, logger, policy), logger, 2, time.Minute)
+	ctx := context.Background()
+
+	// The request is neither retried nor counted against the host
+	for i := 0; i < 3; i++ {
+		_, err := f.Fetch(ctx, "https://shop.test", WithExtractors("ads"))
+		assert.ErrorIs(t, err, ErrUnknownExtractor)
+		assert.Equal(t, ErrorClassInvalidRequest, ClassifyError(err))
+	}
+	assert.Equal(t, int32(3), next.calls.Load())
+
+	next.err = nil
+	_, err := f.Fetch(ctx, "https://shop.test")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), next.calls.Load())
+}
+
+func TestFetch_Meta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/complete":
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+				<html>
+					<head>
+						<meta charset="utf-8">
+						<meta name="viewport" content="width=device-width, initial-scale=1">
+						<meta name="Description" content="Furniture for every room">
+						<meta name="robots" content="index, follow">
+						<link rel="canonical" href="https://shop.test/">
+						<meta property="og:title" content="Shop">
+						<meta property="og:type" content="website">
+						<meta property="og:image" content="https://shop.test/a.jpg">
+						<meta property="og:image" content="https://shop.test/b.jpg">
+						<meta property="og:url" content="https://shop.test/">
+						<meta name="twitter:card" content="summary_large_image">
+						<title>Shop</title>
+					</head>
+				</html>`))
+		case "/plain":
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+				<html>
+					<head>
+						<base href="https://shop.test/sofas/">
+						<meta name="viewport" content="width=device-width">
+						<meta name="description" content="Sofas">
+						<link rel="canonical" href="grey">
+					</head>
+				</html>`))
+		default:
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+				<html>
+					<head>
+						<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">
+						<meta name="description" content="First">
+						<meta name="description" content="Second">
+						<meta name="robots" content="noindex, nofollow">
+						<link rel="canonical" href="/a">
+						<link rel="canonical" href="/b">
+						<meta property="og:title" content="Shop">
+						<meta name="twitter:title" content="Shop">
+						<meta name="twitter:title" content="Shop again">
+					</head>
+				</html>`))
+		}
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL+"/complete")
+	assert.NoError(t, err)
+
+	meta := result.Meta
+	assert.Equal(t, "utf-8", meta.Charset)
+	assert.Equal(t, "width=device-width, initial-scale=1", meta.Viewport)
+	assert.Equal(t, "Furniture for every room", meta.Description)
+	assert.Equal(t, "index, follow", meta.Robots)
+	assert.Equal(t, "https://shop.test/", meta.Canonical)
+	assert.Equal(t, "https://shop.test/a.jpg", meta.OpenGraph["og:image"])
+	assert.Equal(t, "summary_large_image", meta.Twitter["twitter:card"])
+	assert.Empty(t, meta.Warnings)
+
+	// Open Graph, Twitter Cards and the charset are optional
+	result, err = f.Fetch(context.Background(), server.URL+"/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://shop.test/sofas/grey", result.Meta.Canonical)
+	assert.Empty(t, result.Meta.Warnings)
+
+	result, err = f.Fetch(context.Background(), server.URL+"/incomplete")
+	assert.NoError(t, err)
+
+	meta = result.Meta
+	assert.Equal(t, "ISO-8859-1", meta.Charset)
+	assert.Equal(t, "First", meta.Description)
+	assert.Equal(t, server.URL+"/a", meta.Canonical)
+	assert.Equal(t, "Shop", meta.Twitter["twitter:title"])
+
+	var codes []string
+	for _, w := range meta.Warnings {
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []string{
+		"duplicate_description",
+		"duplicate_canonical",
+		"missing_viewport",
+		"noindex",
+		"missing_og_property",
+		"missing_og_property",
+		"missing_og_property",
+		"missing_twitter_card",
+		"duplicate_twitter_property",
+	}, codes)
+	assert.Equal(t, "The page declares 2 canonical links", meta.Warnings[1].Message)
+}
+
+func TestFetch_StructuredData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<head>
+					<title>Sofa</title>
+					<script type="application/ld+json">
+						{
+							"@context": "https://schema.org",
+							"@type": "Product",
+							"name": "Sofa",
+							"offers": {"@type": "Offer", "price": 499, "priceCurrency": "EUR"}
+						}
+					</script>
+					<script type="application/ld+json">
+						{
+							"@context": "https://schema.org",
+							"@graph": [
+								{"@type": "Organization", "@id": "#org", "name": "Shop"},
+								{
+									"@type": "BreadcrumbList",
+									"itemListElement": [
+										{"@type": "ListItem", "position": 1, "name": "Home", "item": "https://shop.test/"},
+										{"@type": "ListItem", "name": "Sofas"}
+									]
+								}
+							]
+						}
+					</script>
+					<script type="application/ld+json">{"@type": "Product",</script>
+				</head>
+				<body>
+					<div itemscope itemtype="https://schema.org/Product">
+						<h1 itemprop="name">Armchair <small>grey</small></h1>
+						<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+							<span itemprop="price" content="199.00">199 €</span>
+						</div>
+						<a itemprop="url" href="/armchair">Details</a>
+					</div>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Sofa", result.Title)
+
+	items := result.StructuredData.Items
+	assert.Len(t, items, 4)
+
+	product := items[0]
+	assert.Equal(t, FormatJSONLD, product.Format)
+	assert.True(t, product.Is("Product"))
+	assert.Equal(t, "499", product.Properties["offers"][0].Item.Properties["price"][0].Text)
+
+	assert.Equal(t, "#org", items[1].ID)
+	assert.True(t, items[2].Is("BreadcrumbList"))
+	assert.Len(t, items[2].Properties["itemListElement"], 2)
+
+	armchair := items[3]
+	assert.Equal(t, FormatMicrodata, armchair.Format)
+	assert.Equal(t, []string{"Product"}, armchair.Types)
+	assert.Equal(t, "Armchair grey", armchair.Properties["name"][0].Text)
+	assert.Equal(t, "/armchair", armchair.Properties["url"][0].Text)
+	assert.Equal(t, "199.00", armchair.Properties["offers"][0].Item.Properties["price"][0].Text)
+
+	assert.Equal(t, []StructuredDataError{
+		{Format: FormatJSONLD, Message: "invalid JSON-LD: unexpected end of JSON input"},
+		{Format: FormatJSONLD, Type: "Organization", Message: "Organization is missing the required property url"},
+		{Format: FormatJSONLD, Type: "ListItem", Message: "ListItem is missing the required property position"},
+		{Format: FormatMicrodata, Type: "Offer", Message: "Offer is missing the required property priceCurrency or priceSpecification"},
+	}, result.StructuredData.Errors)
+}
+
+func TestFetch_Forms(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/register" {
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+				<html>
+					<body>
+						<form action="/register" method="post">
+							<input type="password" name="password" autocomplete="new-password">
+						</form>
+					</body>
+				</html>`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<body>
+					<form id="login" action="/session#top" method="post">
+						<label for="user">E-mail</label>
+						<input id="user" type="email" name="email" required>
+						<label>Password <input type="password" name="password"></label>
+						<input type="hidden" name="csrf" value="token">
+						<button>Sign in</button>
+					</form>
+					<form action="https://accounts.example.com/register" method="post">
+						<input type="text" name="name">
+						<input type="password" name="password" autocomplete="new-password">
+						<input type="password" name="password_confirm">
+						<input type="submit" value="Create account">
+					</form>
+					<form role="search" action="/search">
+						<input type="text" name="q" placeholder="Search">
+					</form>
+					<form action="http://news.example.com/subscribe" method="post">
+						<input type="email" name="newsletter_email">
+						<button type="button">Cancel</button>
+						<button type="submit">Subscribe</button>
+					</form>
+					<form id="pay" action="/orders" method="post"></form>
+					<input form="pay" name="cardnumber" autocomplete="cc-number">
+					<input form="pay" type="image" src="/pay.png" alt="Pay now">
+					<form action="/auth" method="get">
+						<input type="password" name="pin">
+					</form>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL+"/account")
+	assert.NoError(t, err)
+	assert.True(t, result.HasLoginForm)
+	assert.Len(t, result.Forms, 6)
+
+	login := result.Forms[0]
+	assert.Equal(t, FormKindLogin, login.Kind)
+	assert.Equal(t, server.URL+"/session", login.Action)
+	assert.Equal(t, "POST", login.Method)
+	assert.Equal(t, []FormElement{
+		{Name: "email", Type: "email", ID: "user", Label: "E-mail", Required: true},
+		{Name: "password", Type: "password", Label: "Password"},
+		{Name: "csrf", Type: "hidden"},
+	}, login.Elements)
+	assert.Equal(t, []FormElement{{Type: "submit", Label: "Sign in"}}, login.Submits)
+	assert.Empty(t, login.Flags)
+
+	signup := result.Forms[1]
+	assert.Equal(t, FormKindSignup, signup.Kind)
+	assert.Equal(t, "Create account", signup.Submits[0].Label)
+	assert.Equal(t, []FormFlag{{Code: "cross_origin_password", Message: "The password form submits to another origin, accounts.example.com"}}, signup.Flags)
+
+	search := result.Forms[2]
+	assert.Equal(t, FormKindSearch, search.Kind)
+	assert.Equal(t, "GET", search.Method)
+	assert.Equal(t, server.URL+"/search", search.Action)
+
+	newsletter := result.Forms[3]
+	assert.Equal(t, FormKindNewsletter, newsletter.Kind)
+	assert.Equal(t, []FormElement{{Type: "submit", Label: "Subscribe"}}, newsletter.Submits)
+	assert.Equal(t, "insecure_action", newsletter.Flags[0].Code)
+
+	checkout := result.Forms[4]
+	assert.Equal(t, FormKindCheckout, checkout.Kind)
+	assert.Equal(t, "cardnumber", checkout.Elements[0].Name)
+	assert.Equal(t, "Pay now", checkout.Submits[0].Label)
+
+	pin := result.Forms[5]
+	assert.Equal(t, FormKindLogin, pin.Kind)
+	assert.Equal(t, "password_in_url", pin.Flags[0].Code)
+
+	// Any password input counts as login form, whatever the form is
+	// classified as
+	result, err = f.Fetch(context.Background(), server.URL+"/register")
+	assert.NoError(t, err)
+	assert.Equal(t, FormKindSignup, result.Forms[0].Kind)
+	assert.True(t, result.HasLoginForm)
+}
+
+func TestFetch_Images(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<body>
+					<img src="/sofa.jpg" alt="Grey sofa" width="640" height="480" loading="lazy">
+					<img src="banner.png" alt="" srcset="/banner-1x.png 1x, /banner,2x.png 2x,/banner-3x.png">
+					<picture>
+						<source srcset="/chair.webp 480w, /chair-large.webp 960w" sizes="50vw" type="image/webp">
+						<img src="/chair.jpg" width="480">
+					</picture>
+					<video><source src="/intro.mp4" type="video/mp4"></video>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL+"/living/")
+	assert.NoError(t, err)
+	assert.Len(t, result.Images, 3)
+
+	sofa := result.Images[0]
+	assert.Equal(t, server.URL+"/sofa.jpg", sofa.Src)
+	assert.Equal(t, "Grey sofa", sofa.Alt)
+	assert.Equal(t, "640", sofa.Width)
+	assert.Equal(t, "lazy", sofa.Loading)
+	assert.Empty(t, sofa.Warnings)
+
+	banner := result.Images[1]
+	assert.Equal(t, server.URL+"/living/banner.png", banner.Src)
+	assert.True(t, banner.HasAlt)
+	assert.Equal(t, []SrcsetCandidate{
+		{URL: server.URL + "/banner-1x.png", Descriptor: "1x"},
+		{URL: server.URL + "/banner,2x.png", Descriptor: "2x"},
+		{URL: server.URL + "/banner-3x.png"},
+	}, banner.Srcset)
+	assert.Equal(t, []ImageWarning{{Code: "missing_dimensions", Message: "The image has no width and height, the layout may shift while it loads"}}, banner.Warnings)
+
+	chair := result.Images[2]
+	assert.Equal(t, []ImageSource{{
+		Srcset: []SrcsetCandidate{{URL: server.URL + "/chair.webp", Descriptor: "480w"}, {URL: server.URL + "/chair-large.webp", Descriptor: "960w"}},
+		Sizes:  "50vw",
+		Type:   "image/webp",
+	}}, chair.Sources)
+	assert.False(t, chair.HasAlt)
+	assert.Equal(t, []ImageWarning{{Code: "missing_dimensions", Message: "The image has no width and height, the layout may shift while it loads"}}, chair.Warnings)
+}
+
+func TestFetch_Resources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<head>
+					<link rel="stylesheet" href="/app.css">
+					<link rel="preload" href="/fonts/sans.woff2" as="font" type="font/woff2" crossorigin>
+					<link rel="shortcut icon" href="/favicon.ico">
+					<link rel="manifest" href="/site.webmanifest">
+					<link rel="canonical" href="/">
+					<script src="app.js" defer></script>
+					<script>window.inline = true</script>
+				</head>
+				<body>
+					<iframe src="https://video.example.com/embed/1"></iframe>
+					<video src="/intro.mp4" poster="/intro.jpg">
+						<source src="/intro.webm" type="video/webm">
+						<track src="/intro.vtt" kind="captions">
+					</video>
+					<picture><source srcset="/chair.webp"><img src="/chair.jpg" alt=""></picture>
+					<script src="/app.js"></script>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL+"/shop/")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Resource{
+		{Kind: ResourceStylesheet, URL: server.URL + "/app.css", Tag: "link"},
+		{Kind: ResourceFont, URL: server.URL + "/fonts/sans.woff2", Tag: "link", Type: "font/woff2"},
+		{Kind: ResourceIcon, URL: server.URL + "/favicon.ico", Tag: "link"},
+		{Kind: ResourceManifest, URL: server.URL + "/site.webmanifest", Tag: "link"},
+		{Kind: ResourceScript, URL: server.URL + "/shop/app.js", Tag: "script"},
+		{Kind: ResourceIframe, URL: "https://video.example.com/embed/1", Tag: "iframe"},
+		{Kind: ResourceMedia, URL: server.URL + "/intro.mp4", Tag: "video"},
+		{Kind: ResourceMedia, URL: server.URL + "/intro.jpg", Tag: "video"},
+		{Kind: ResourceMedia, URL: server.URL + "/intro.webm", Tag: "source", Type: "video/webm"},
+		{Kind: ResourceMedia, URL: server.URL + "/intro.vtt", Tag: "track"},
+		{Kind: ResourceScript, URL: server.URL + "/app.js", Tag: "script"},
+	}, result.Resources)
+	assert.True(t, result.Resources[0].Blocking())
+	assert.False(t, result.Resources[1].Blocking())
+}
+
+func TestFetch_Links(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<head>
+					<base href="/catalog/">
+					<base href="/ignored/">
+				</head>
+				<body>
+					<a href="sofas">Sofas</a>
+					<a href="#reviews">Reviews</a>
+					<a href="` + "http://" + r.Host + `/catalog/sofas">Sofas again</a>
+					<a href="//cdn.shop.co.uk/brochure.pdf">Brochure</a>
+					<a href="https://blog.shop.co.uk/">Blog</a>
+					<a href="https://partner.example.com/">Partner</a>
+					<a href="mailto:service@shop.co.uk">Mail</a>
+					<a href="tel:+441234">Call</a>
+					<a href="javascript:void(0)">Menu</a>
+					<a href="data:text/plain,hi">Data</a>
+					<a href="ftp://files.shop.co.uk/">Files</a>
+					<map><area href="/" alt="Home"></map>
+					<a>No link</a>
+					<img src="sofa.jpg" alt="" width="1" height="1">
+					<form action="search"></form>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL+"/catalog/#top")
+	assert.NoError(t, err)
+
+	assert.Equal(t, server.URL+"/catalog/", result.BaseURL)
+	assert.Equal(t, server.URL+"/catalog/sofa.jpg", result.Images[0].Src)
+	assert.Equal(t, server.URL+"/catalog/search", result.Forms[0].Action)
+
+	var types []LinkType
+	for _, a := range result.Anchors {
+		types = append(types, a.Type)
+	}
+	assert.Equal(t, []LinkType{
+		LinkTypeSameHost,
+		LinkTypeSamePage,
+		LinkTypeExternal,
+		LinkTypeExternal,
+		LinkTypeExternal,
+		LinkTypeMailto,
+		LinkTypeTel,
+		LinkTypeJavascript,
+		LinkTypeData,
+		LinkTypeOther,
+		LinkTypeSameHost,
+	}, types)
+
+	sofas := result.Anchors[0]
+	assert.Equal(t, server.URL+"/catalog/sofas", sofas.URL)
+	assert.Equal(t, "sofas", sofas.Href)
+	assert.Equal(t, LinkTypeSameHost, sofas.Type)
+	assert.False(t, sofas.External)
+	assert.Equal(t, server.URL+"/catalog/#reviews", result.Anchors[1].URL)
+	// The absolute link to the sofas is the same link as the relative one
+	assert.Equal(t, "http://cdn.shop.co.uk/brochure.pdf", result.Anchors[2].URL)
+	assert.True(t, result.Anchors[2].External)
+	assert.False(t, result.Anchors[5].External)
+	assert.False(t, result.Anchors[5].Checkable())
+	assert.Equal(t, server.URL+"/", result.Anchors[10].URL)
+}
+
+func TestDocumentURL_Anchor(t *testing.T) {
+	doc := newDocumentURL("https://www.shop.co.uk/sofas?page=2")
+
+	tests := []struct {
+		href     string
+		url      string
+		linkType LinkType
+	}{
+		{"?page=2#list", "https://www.shop.co.uk/sofas?page=2#list", LinkTypeSamePage},
+		{"", "https://www.shop.co.uk/sofas?page=2", LinkTypeSamePage},
+		{"https://WWW.shop.co.uk/chairs", "https://WWW.shop.co.uk/chairs", LinkTypeSameHost},
+		{"//cdn.shop.co.uk/app.js", "https://cdn.shop.co.uk/app.js", LinkTypeSameSite},
+		{"https://other.co.uk/", "https://other.co.uk/", LinkTypeExternal},
+		{"MAILTO:service@shop.co.uk", "MAILTO:service@shop.co.uk", LinkTypeMailto},
+		{"http://[::1", "http://[::1", LinkTypeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			a := doc.anchor(tt.href)
+			assert.Equal(t, tt.url, a.URL)
+			assert.Equal(t, tt.linkType, a.Type)
+		})
+	}
+}
+
+func TestFetch_AnchorContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<body>
+					<header>
+						<a href="/"><img src="/logo.svg" alt="Shop"></a>
+						<nav><a href="/sofas" title="All sofas" hreflang="en">Sofas <b>&amp; couches</b></a></nav>
+					</header>
+					<main>
+						<p>Our sale ends soon, <a href="/sale">click here</a>.</p>
+						<a href="https://partner.test/" target="_blank" rel="Sponsored">Partner</a>
+						<a href="https://safe.test/" target="_blank" rel="noopener nofollow">Safe</a>
+						<a href="/cart" aria-label="Cart"><svg></svg></a>
+						<a href="/empty"> </a>
+					</main>
+					<div role="contentinfo"><a href="/imprint">Imprint</a></div>
+					<a href="/outside">Outside</a>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, result.Anchors, 9)
+
+	anchors := map[string]Anchor{}
+	for _, a := range result.Anchors {
+		anchors[a.Href] = a
+	}
+
+	assert.Equal(t, "Shop", anchors["/"].Text)
+	assert.Equal(t, RegionHeader, anchors["/"].Region)
+
+	sofas := anchors["/sofas"]
+	assert.Equal(t, "Sofas & couches", sofas.Text)
+	assert.Equal(t, "All sofas", sofas.Title)
+	assert.Equal(t, "en", sofas.Hreflang)
+	assert.Equal(t, RegionNav, sofas.Region)
+	assert.Empty(t, sofas.Warnings)
+
+	assert.Equal(t, RegionMain, anchors["/sale"].Region)
+	assert.Equal(t, []LinkWarning{{Code: "generic_text", Message: `The link text "click here" does not describe where the link leads`}}, anchors["/sale"].Warnings)
+
+	partner := anchors["https://partner.test/"]
+	assert.True(t, partner.HasRel("sponsored"))
+	assert.Equal(t, "_blank", partner.Target)
+	assert.Equal(t, "unsafe_target_blank", partner.Warnings[0].Code)
+	assert.Empty(t, anchors["https://safe.test/"].Warnings)
+
+	assert.Equal(t, "Cart", anchors["/cart"].AriaLabel)
+	assert.Empty(t, anchors["/cart"].Warnings)
+	assert.Equal(t, "empty_text", anchors["/empty"].Warnings[0].Code)
+
+	assert.Equal(t, RegionFooter, anchors["/imprint"].Region)
+	assert.Equal(t, RegionOther, anchors["/outside"].Region)
+}
+
+func TestFetch_Outline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<body>
+					<h1><img src="/logo.svg" alt="Shop"></h1>
+					<h2>Sofas</h2>
+					<h4>Corner   sofas</h4>
+					<h2>Tables</h2>
+					<h3> </h3>
+					<h1>Sale</h1>
+					<h2>Chairs</h2>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+
+	outline := result.Outline
+	assert.Len(t, outline.Headings, 7)
+	assert.Equal(t, Heading{Level: 1, Text: "Shop", Warnings: []HeadingWarning{}}, outline.Headings[0])
+	assert.Equal(t, "Corner sofas", outline.Headings[2].Text)
+	assert.Equal(t, []HeadingWarning{{Code: "skipped_level", Message: "The h4 follows a h2, skipping a level"}}, outline.Headings[2].Warnings)
+	assert.Empty(t, outline.Headings[3].Warnings)
+	assert.Equal(t, []HeadingWarning{{Code: "empty_heading", Message: "The h3 has no text"}}, outline.Headings[4].Warnings)
+	assert.Equal(t, []HeadingWarning{{Code: "multiple_h1", Message: "The page has 2 h1 headings"}}, outline.Warnings)
+	assert.Equal(t, 3, outline.WarningCount())
+
+	// Every heading is listed once, in document order
+	assert.Equal(t, []string{"Shop", "Sale"}, result.HeaderMap["h1"])
+	assert.Equal(t, []string{"Sofas", "Tables", "Chairs"}, result.HeaderMap["h2"])
+}
+
+func TestOutline_Tree(t *testing.T) {
+	outline := Outline{Headings: []Heading{{Level: 2, Text: "Intro"}, {Level: 1, Text: "Shop"}, {Level: 3, Text: "Sofas"}, {Level: 2, Text: "Tables"}, {Level: 1, Text: "Sale"}}}
+
+	tree := outline.Tree()
+	assert.Len(t, tree, 3)
+	assert.Equal(t, "Intro", tree[0].Text)
+	assert.Empty(t, tree[0].Children)
+
+	shop := tree[1]
+	assert.Len(t, shop.Children, 2)
+	assert.Equal(t, "Sofas", shop.Children[0].Text)
+	assert.Equal(t, "Tables", shop.Children[1].Text)
+	assert.Equal(t, "Sale", tree[2].Text)
+
+	missing := (&headingCollector{}).result()
+	assert.Equal(t, []HeadingWarning{{Code: "missing_h1", Message: "The page has no h1 heading"}}, missing.Warnings)
+	assert.Empty(t, missing.Tree())
+}
+
+func TestFetch_Accessibility(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<body>
+					<div id="main" role="main"></div>
+					<div id="main" role="banana" aria-labeled="x"></div>
+					<div role="banana navigation"></div>
+					<div role="doc-banana"></div>
+					<form>
+						<label for="email">Email</label><input id="email" name="email">
+						<label>Name <input name="name"></label>
+						<input name="q" aria-label="Search">
+						<input name="phone">
+						<select name="size"></select>
+						<input type="hidden" name="token">
+						<input type="submit" value="Send">
+						<button><img src="/go.svg" alt="Go"></button>
+						<button><svg></svg></button>
+					</form>
+					<img src="/logo.svg">
+					<img src="/spacer.gif" alt="">
+					<a href="/cart"><svg></svg></a>
+					<a href="/home" title="Home"></a>
+					<table><tr><th>Size</th></tr></table>
+					<table><tr><td>1</td></tr></table>
+					<table role="presentation"><tr><td>layout</td></tr></table>
+					<table role="banana none"><tr><td>layout</td></tr></table>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+
+	codes := []string{}
+	for _, issue := range result.Accessibility {
+		codes = append(codes, issue.Code)
+	}
+	assert.Equal(t, []string{
+		"missing_lang", "duplicate_id", "missing_label", "missing_label",
+		"invalid_aria_role", "invalid_aria_attribute", "invalid_aria_role", "no_button_name",
+		"missing_alt", "no_link_name", "table_without_headers",
+	}, codes)
+
+	assert.Equal(t, AccessibilityIssue{Code: "missing_lang", Severity: SeverityError, Criterion: "3.1.1 Language of Page", Message: "The html element has no lang attribute"}, result.Accessibility[0])
+	assert.Equal(t, AccessibilityIssue{Code: "duplicate_id", Severity: SeverityWarning, Criterion: "4.1.2 Name, Role, Value", Message: `The id "main" is used by 2 elements`}, result.Accessibility[1])
+	assert.Equal(t, `The <div> has the invalid role "doc-banana"`, result.Accessibility[6].Message)
+	assert.Equal(t, `The text input "phone" has no label`, result.Accessibility[2].Message)
+	assert.Equal(t, `The select "size" has no label`, result.Accessibility[3].Message)
+	assert.Equal(t, AccessibilityIssue{Code: "missing_alt", Severity: SeverityError, Criterion: "1.1.1 Non-text Content", Message: "The image /logo.svg has no alt attribute"}, result.Accessibility[8])
+	assert.Equal(t, "The link to /cart has no accessible name", result.Accessibility[9].Message)
+	assert.Equal(t, SeverityWarning, result.Accessibility[10].Severity)
+}
+
+// trackingExtractor detects the tracking snippet of a page
+type trackingExtractor struct {
+	found bool
+}
+
+func (e *trackingExtractor) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken || tok.Data != "script" {
+		return
+	}
+
+	w.WhenClosed(func(text string) {
+		if strings.Contains(text, "shopTrack(") {
+			e.found = true
+		}
+	})
+}
+
+func (e *trackingExtractor) Result(r *FetchResult) {
+	r.Extracted["tracking"] = e.found
+}
+
+func TestFetch_Extractors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<head><title>Shop</title><script>shopTrack('pageview');</script></head>
+				<body><h1>Shop</h1><a href="/sale">Sale</a></body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	registry := DefaultRegistry()
+	registry.Register(NewExtractor("tracking", func(*Page) PageExtractor { return &trackingExtractor{} }))
+	assert.Panics(t, func() { registry.Register(NewExtractor(ExtractorTitle, nil)) })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20, WithRegistry(registry))
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shop", result.Title)
+	assert.Len(t, result.Anchors, 1)
+	assert.Equal(t, map[string]any{"tracking": true}, result.Extracted)
+
+	result, err = f.Fetch(context.Background(), server.URL, WithExtractors(ExtractorTitle, "tracking"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Shop", result.Title)
+	assert.Empty(t, result.Anchors)
+	assert.Empty(t, result.HeaderMap)
+	assert.Equal(t, true, result.Extracted["tracking"])
+
+	_, err = f.Fetch(context.Background(), server.URL, WithExtractors("ads"))
+	assert.ErrorIs(t, err, ErrUnknownExtractor)
+}
+
+func TestFetch_NestedText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<!DOCTYPE html>
+			<html>
+				<head><title>Shop</title></head>
+				<body>
+					<h1>Sale <a href="/sale">now</a></h1>
+					<h2>Sofas<br>and chairs</h2>
+					<h2>Tables</h2>
+				</body>
+			</html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := NewFetcher(server.Client(), logger, 10<<20)
+
+	result, err := f.Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shop", result.Title)
+	assert.Equal(t, []string{"Sale now"}, result.HeaderMap["h1"])
+	assert.Equal(t, []string{"Sofas and chairs", "Tables"}, result.HeaderMap["h2"])
+	// The link inside the heading is not consumed while its text is read
+	assert.Len(t, result.Anchors, 1)
+}
@@ -0,0 +1,397 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FormKind is the purpose of a form guessed from its controls
+type FormKind string
+
+const (
+	FormKindLogin      FormKind = "login"
+	FormKindSignup     FormKind = "signup"
+	FormKindSearch     FormKind = "search"
+	FormKindNewsletter FormKind = "newsletter"
+	FormKindCheckout   FormKind = "checkout"
+	FormKindOther      FormKind = "other"
+)
+
+// FormFlag is a security problem of a form
+type FormFlag struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// FormElement is a control of a form. Submit controls are labeled by their
+// text or value.
+type FormElement struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	Label        string `json:"label,omitempty"`
+	Required     bool   `json:"required"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+}
+
+// Form is a form of a page. Action is resolved against the base URL of the
+// document.
+type Form struct {
+	ID       string        `json:"id,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	Action   string        `json:"action"`
+	Method   string        `json:"method"`
+	Elements []FormElement `json:"elements"`
+	Submits  []FormElement `json:"submits"`
+	Kind     FormKind      `json:"kind"`
+	Flags    []FormFlag    `json:"flags"`
+}
+
+// HasPassword reports whether the form has a password field
+func (f *Form) HasPassword() bool {
+	return f.count(func(e *FormElement) bool { return e.Type == "password" }) > 0
+}
+
+func (f *Form) count(match func(e *FormElement) bool) int {
+	n := 0
+	for i := range f.Elements {
+		if match(&f.Elements[i]) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// formState is a form while its controls are collected
+type formState struct {
+	form     Form
+	role     string
+	class    string
+	elements []*FormElement
+	submits  []*FormElement
+}
+
+// detachedControl is a control outside of its form, associated through the
+// form attribute
+type detachedControl struct {
+	formID string
+	el     *FormElement
+	submit bool
+}
+
+// formCollector gathers the forms of a page along with their controls and
+// labels
+type formCollector struct {
+	doc   *documentURL
+	forms []*formState
+	// current is the open form
+	current  *formState
+	detached []detachedControl
+	// labels are the texts of <label for> elements keyed by the id they
+	// point to
+	labels map[string]string
+	// label is the open <label> without for attribute, it labels the first
+	// control it wraps
+	label *wrappingLabel
+	// password is set by any password input, inside a form or not
+	password bool
+}
+
+type wrappingLabel struct {
+	control *FormElement
+}
+
+func newFormCollector(doc *documentURL) *formCollector {
+	return &formCollector{doc: doc, labels: map[string]string{}}
+}
+
+// Token implements PageExtractor
+func (c *formCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	switch tok.Data {
+	case "form":
+		c.formTag(w, tok)
+	case "label":
+		c.labelTag(w, tok)
+	case "input", "select", "textarea", "button":
+		c.control(w, tok)
+	}
+}
+
+// Result implements PageExtractor. A page has a login form when it has a
+// password input anywhere, whatever kind its form is classified as.
+func (c *formCollector) Result(r *FetchResult) {
+	r.Forms = c.result()
+	r.HasLoginForm = c.password
+}
+
+// formTag opens a <form>
+func (c *formCollector) formTag(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+
+	s := &formState{
+		form: Form{
+			ID:     attrs["id"],
+			Name:   attrs["name"],
+			Action: attrs["action"],
+			Method: strings.ToUpper(strings.TrimSpace(attrs["method"])),
+		},
+		role:  strings.ToLower(attrs["role"]),
+		class: attrs["class"],
+	}
+	if s.form.Method != "POST" && s.form.Method != "DIALOG" {
+		s.form.Method = "GET"
+	}
+
+	c.forms = append(c.forms, s)
+	c.current = s
+
+	w.WhenClosedOnly(func() {
+		if c.current == s {
+			c.current = nil
+		}
+	})
+}
+
+// labelTag opens a <label>
+func (c *formCollector) labelTag(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+
+	if target := strings.TrimSpace(attrs["for"]); target != "" {
+		w.WhenClosed(func(text string) {
+			if _, ok := c.labels[target]; !ok {
+				c.labels[target] = text
+			}
+		})
+		return
+	}
+
+	l := &wrappingLabel{}
+	c.label = l
+	w.WhenClosed(func(text string) {
+		if l.control != nil && l.control.Label == "" {
+			l.control.Label = text
+		}
+		if c.label == l {
+			c.label = nil
+		}
+	})
+}
+
+// control collects an <input>, <select>, <textarea> or <button>
+func (c *formCollector) control(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+
+	el := &FormElement{
+		Name:         attrs["name"],
+		ID:           attrs["id"],
+		Autocomplete: strings.ToLower(strings.TrimSpace(attrs["autocomplete"])),
+	}
+	_, el.Required = attrs["required"]
+
+	typ := strings.ToLower(strings.TrimSpace(attrs["type"]))
+	submit := false
+
+	switch tok.Data {
+	case "input":
+		if typ == "" {
+			typ = "text"
+		}
+
+		switch typ {
+		case "submit", "image":
+			submit = true
+			el.Label = attrs["value"]
+			if typ == "image" {
+				el.Label = attrs["alt"]
+			}
+		case "button", "reset":
+			return
+		}
+	case "button":
+		if typ != "" && typ != "submit" {
+			return
+		}
+		typ = "submit"
+		submit = true
+		w.WhenClosed(func(text string) {
+			if el.Label == "" {
+				el.Label = text
+			}
+		})
+	default:
+		typ = tok.Data
+	}
+	el.Type = typ
+	if tok.Data == "input" && typ == "password" {
+		c.password = true
+	}
+
+	if !submit && typ != "hidden" && c.label != nil && c.label.control == nil {
+		c.label.control = el
+	}
+
+	if formID := strings.TrimSpace(attrs["form"]); formID != "" {
+		c.detached = append(c.detached, detachedControl{formID: formID, el: el, submit: submit})
+		return
+	}
+
+	if c.current == nil {
+		return
+	}
+
+	if submit {
+		c.current.submits = append(c.current.submits, el)
+	} else {
+		c.current.elements = append(c.current.elements, el)
+	}
+}
+
+// result returns the forms in document order, classified and flagged
+func (c *formCollector) result() []Form {
+	for _, d := range c.detached {
+		for _, s := range c.forms {
+			if s.form.ID != d.formID {
+				continue
+			}
+
+			if d.submit {
+				s.submits = append(s.submits, d.el)
+			} else {
+				s.elements = append(s.elements, d.el)
+			}
+			break
+		}
+	}
+
+	forms := make([]Form, 0, len(c.forms))
+	for _, s := range c.forms {
+		f := s.form
+		f.Action = c.doc.resolve(f.Action)
+		f.Elements = make([]FormElement, 0, len(s.elements))
+		f.Submits = make([]FormElement, 0, len(s.submits))
+
+		for _, el := range s.elements {
+			if el.Label == "" && el.ID != "" {
+				el.Label = c.labels[el.ID]
+			}
+			f.Elements = append(f.Elements, *el)
+		}
+		for _, el := range s.submits {
+			f.Submits = append(f.Submits, *el)
+		}
+
+		f.Kind = classifyForm(&f, s)
+		f.Flags = c.flags(&f)
+		forms = append(forms, f)
+	}
+
+	return forms
+}
+
+// textFields are the input types a user types into
+var textFields = map[string]bool{
+	"text": true, "email": true, "password": true, "search": true, "tel": true,
+	"url": true, "number": true, "textarea": true,
+}
+
+// searchNames are the usual names of search fields
+var searchNames = map[string]bool{
+	"q": true, "s": true, "query": true, "search": true, "keyword": true, "keywords": true, "term": true,
+}
+
+// classifyForm guesses the purpose of a form from its fields, autocomplete
+// hints and the words used in its action, names and submit labels
+func classifyForm(f *Form, s *formState) FormKind {
+	hint := []string{f.Action, f.ID, f.Name, s.class}
+	for _, el := range f.Submits {
+		hint = append(hint, el.Label)
+	}
+	hints := strings.ToLower(strings.Join(hint, " "))
+
+	mentions := func(words ...string) bool {
+		for _, word := range words {
+			if strings.Contains(hints, word) {
+				return true
+			}
+		}
+		return false
+	}
+
+	passwords := f.count(func(e *FormElement) bool { return e.Type == "password" })
+	newPasswords := f.count(func(e *FormElement) bool {
+		name := strings.ToLower(e.Name)
+		return e.Autocomplete == "new-password" || (e.Type == "password" && (strings.Contains(name, "confirm") || strings.Contains(name, "repeat")))
+	})
+	cards := f.count(func(e *FormElement) bool {
+		name := strings.ToLower(e.Name)
+		return strings.HasPrefix(e.Autocomplete, "cc-") || strings.Contains(name, "card") || strings.Contains(name, "cvc") || strings.Contains(name, "cvv")
+	})
+	searches := f.count(func(e *FormElement) bool {
+		return e.Type == "search" || (e.Type == "text" && searchNames[strings.ToLower(e.Name)])
+	})
+	emails := f.count(func(e *FormElement) bool {
+		return e.Type == "email" || (textFields[e.Type] && strings.Contains(strings.ToLower(e.Name), "email"))
+	})
+	fields := f.count(func(e *FormElement) bool { return textFields[e.Type] })
+
+	switch {
+	case cards > 0 || mentions("checkout", "payment"):
+		return FormKindCheckout
+	case passwords > 1 || newPasswords > 0 || (passwords == 1 && mentions("signup", "sign-up", "sign up", "register", "registration")):
+		return FormKindSignup
+	case passwords == 1:
+		return FormKindLogin
+	case s.role == "search" || (searches > 0 && fields <= 2):
+		return FormKindSearch
+	case emails == 1 && (fields <= 2 || mentions("newsletter", "subscribe")):
+		return FormKindNewsletter
+	default:
+		return FormKindOther
+	}
+}
+
+// flags reports password forms that leak credentials and forms of secure
+// pages posting to insecure targets
+func (c *formCollector) flags(f *Form) []FormFlag {
+	flags := []FormFlag{}
+
+	page := c.doc.page
+	action, err := url.Parse(f.Action)
+	if err != nil || page == nil {
+		return flags
+	}
+	insecure := action.Scheme == "http"
+
+	if !f.HasPassword() {
+		if insecure && page.Scheme == "https" {
+			flags = append(flags, FormFlag{Code: "insecure_action", Message: fmt.Sprintf("The form of a secure page submits to %s over HTTP", f.Action)})
+		}
+		return flags
+	}
+
+	if insecure {
+		flags = append(flags, FormFlag{Code: "insecure_password", Message: "The password form submits over HTTP"})
+	}
+
+	if f.Method == "GET" {
+		flags = append(flags, FormFlag{Code: "password_in_url", Message: "The password form submits with GET, putting the password in the URL"})
+	}
+
+	if (action.Scheme == "http" || action.Scheme == "https") && !sameOrigin(page, action) {
+		flags = append(flags, FormFlag{Code: "cross_origin_password", Message: fmt.Sprintf("The password form submits to another origin, %s", action.Host)})
+	}
+
+	return flags
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
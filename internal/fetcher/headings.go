@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// HeadingWarning is a problem of the heading structure of a page
+type HeadingWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Heading is a h1 to h6 of a page. Text is the whitespace collapsed content
+// of the heading including the alt text of its images.
+type Heading struct {
+	Level    int              `json:"level"`
+	Text     string           `json:"text"`
+	Warnings []HeadingWarning `json:"warnings"`
+}
+
+// Outline is the headings of a page in document order. Warnings are the
+// problems of the page as a whole, like a missing or repeated h1.
+type Outline struct {
+	Headings []Heading        `json:"headings"`
+	Warnings []HeadingWarning `json:"warnings"`
+}
+
+// WarningCount returns the warnings of the outline and its headings
+func (o Outline) WarningCount() int {
+	n := len(o.Warnings)
+	for _, h := range o.Headings {
+		n += len(h.Warnings)
+	}
+
+	return n
+}
+
+// OutlineNode is a heading with the headings of its section
+type OutlineNode struct {
+	Heading
+	Children []*OutlineNode
+}
+
+// Tree nests every heading under the closest preceding heading of a higher
+// level
+func (o Outline) Tree() []*OutlineNode {
+	var roots []*OutlineNode
+	var stack []*OutlineNode
+
+	for _, h := range o.Headings {
+		node := &OutlineNode{Heading: h}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, node)
+	}
+
+	return roots
+}
+
+// headingCollector gathers the headings of a page in the order they start
+type headingCollector struct {
+	headings []*Heading
+	// open is the heading whose content is being read
+	open *Heading
+}
+
+// Token implements PageExtractor
+func (c *headingCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	switch tok.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.headingTag(w, tok)
+	case "img":
+		c.imgTag(tok)
+	}
+}
+
+// Result implements PageExtractor. HeaderMap is derived from the outline.
+func (c *headingCollector) Result(r *FetchResult) {
+	r.Outline = c.result()
+	r.HeaderMap = r.Outline.headerMap()
+}
+
+// headingTag collects a h1 to h6
+func (c *headingCollector) headingTag(w Walker, tok html.Token) {
+	h := &Heading{Level: int(tok.Data[1] - '0')}
+	c.headings = append(c.headings, h)
+
+	c.open = h
+	w.WhenClosed(func(text string) {
+		h.Text = collapseWS(text + " " + h.Text)
+		if c.open == h {
+			c.open = nil
+		}
+	})
+}
+
+// imgTag adds the alt text of an image to the text of the heading around it
+func (c *headingCollector) imgTag(tok html.Token) {
+	if c.open == nil {
+		return
+	}
+
+	if alt, ok := attrMap(tok)["alt"]; ok {
+		c.open.Text = collapseWS(c.open.Text + " " + alt)
+	}
+}
+
+// result returns the outline with skipped levels, empty headings and a
+// missing or repeated h1 flagged
+func (c *headingCollector) result() Outline {
+	o := Outline{Headings: make([]Heading, 0, len(c.headings)), Warnings: []HeadingWarning{}}
+
+	h1s, previous := 0, 0
+	for _, h := range c.headings {
+		h.Warnings = []HeadingWarning{}
+
+		if h.Level == 1 {
+			h1s++
+		}
+
+		if previous > 0 && h.Level > previous+1 {
+			h.Warnings = append(h.Warnings, HeadingWarning{Code: "skipped_level", Message: fmt.Sprintf("The h%d follows a h%d, skipping a level", h.Level, previous)})
+		}
+		previous = h.Level
+
+		if h.Text == "" {
+			h.Warnings = append(h.Warnings, HeadingWarning{Code: "empty_heading", Message: fmt.Sprintf("The h%d has no text", h.Level)})
+		}
+
+		o.Headings = append(o.Headings, *h)
+	}
+
+	switch {
+	case h1s == 0:
+		o.Warnings = append(o.Warnings, HeadingWarning{Code: "missing_h1", Message: "The page has no h1 heading"})
+	case h1s > 1:
+		o.Warnings = append(o.Warnings, HeadingWarning{Code: "multiple_h1", Message: fmt.Sprintf("The page has %d h1 headings", h1s)})
+	}
+
+	return o
+}
+
+// headerMap groups the heading texts by tag, in document order per tag
+func (o Outline) headerMap() map[string][]string {
+	hm := make(map[string][]string)
+	for _, h := range o.Headings {
+		tag := fmt.Sprintf("h%d", h.Level)
+		hm[tag] = append(hm[tag], h.Text)
+	}
+
+	return hm
+}
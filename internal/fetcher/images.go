@@ -0,0 +1,225 @@
+package fetcher
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SrcsetCandidate is a single image of a srcset with its width or density
+// descriptor
+type SrcsetCandidate struct {
+	URL        string `json:"url"`
+	Descriptor string `json:"descriptor,omitempty"`
+}
+
+// ImageSource is a <source> of a <picture>
+type ImageSource struct {
+	Srcset []SrcsetCandidate `json:"srcset"`
+	Sizes  string            `json:"sizes,omitempty"`
+	Media  string            `json:"media,omitempty"`
+	Type   string            `json:"type,omitempty"`
+}
+
+// ImageWarning is a problem of an image found without requesting it
+type ImageWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Image is an <img> of a page. URLs are resolved against the base URL of the
+// document. An empty alt text marks a decorative image, HasAlt tells it apart
+// from a missing alt attribute.
+type Image struct {
+	Src      string            `json:"src"`
+	Srcset   []SrcsetCandidate `json:"srcset"`
+	Sizes    string            `json:"sizes,omitempty"`
+	Sources  []ImageSource     `json:"sources,omitempty"`
+	Alt      string            `json:"alt"`
+	HasAlt   bool              `json:"has_alt"`
+	Width    string            `json:"width,omitempty"`
+	Height   string            `json:"height,omitempty"`
+	Loading  string            `json:"loading,omitempty"`
+	Warnings []ImageWarning    `json:"warnings"`
+}
+
+// URL returns the URL browsers without srcset support load, falling back to
+// the first srcset candidate
+func (i *Image) URL() string {
+	if i.Src != "" {
+		return i.Src
+	}
+
+	if len(i.Srcset) > 0 {
+		return i.Srcset[0].URL
+	}
+
+	return ""
+}
+
+// URLs returns every URL the image may load depending on the browser,
+// starting with URL and followed by the srcset and <source> candidates, each
+// URL once
+func (i *Image) URLs() []string {
+	urls := []string{i.URL()}
+	seen := map[string]bool{urls[0]: true}
+
+	add := func(candidates []SrcsetCandidate) {
+		for _, c := range candidates {
+			if !seen[c.URL] {
+				seen[c.URL] = true
+				urls = append(urls, c.URL)
+			}
+		}
+	}
+
+	add(i.Srcset)
+	for _, source := range i.Sources {
+		add(source.Srcset)
+	}
+
+	return urls
+}
+
+// imageCollector gathers the images of a page
+type imageCollector struct {
+	doc    *documentURL
+	images []Image
+	// picture holds the sources of the open <picture>
+	picture *[]ImageSource
+}
+
+func newImageCollector(doc *documentURL) *imageCollector {
+	return &imageCollector{doc: doc, images: []Image{}}
+}
+
+// Token implements PageExtractor
+func (c *imageCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	switch tok.Data {
+	case "picture":
+		c.pictureTag(w)
+	case "source":
+		c.sourceTag(tok)
+	case "img":
+		c.imgTag(tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *imageCollector) Result(r *FetchResult) {
+	r.Images = c.result()
+}
+
+// pictureTag opens a <picture>, its sources apply to the image inside it
+func (c *imageCollector) pictureTag(w Walker) {
+	sources := &[]ImageSource{}
+	c.picture = sources
+
+	w.WhenClosedOnly(func() {
+		if c.picture == sources {
+			c.picture = nil
+		}
+	})
+}
+
+// sourceTag collects a <source> of the open <picture>. Sources of audio and
+// video elements have no srcset and are ignored.
+func (c *imageCollector) sourceTag(tok html.Token) {
+	attrs := attrMap(tok)
+	if c.picture == nil || attrs["srcset"] == "" {
+		return
+	}
+
+	*c.picture = append(*c.picture, ImageSource{
+		Srcset: c.srcset(attrs["srcset"]),
+		Sizes:  attrs["sizes"],
+		Media:  attrs["media"],
+		Type:   attrs["type"],
+	})
+}
+
+// imgTag collects an <img>
+func (c *imageCollector) imgTag(tok html.Token) {
+	attrs := attrMap(tok)
+
+	img := Image{
+		Srcset:   c.srcset(attrs["srcset"]),
+		Sizes:    attrs["sizes"],
+		Width:    strings.TrimSpace(attrs["width"]),
+		Height:   strings.TrimSpace(attrs["height"]),
+		Loading:  strings.ToLower(strings.TrimSpace(attrs["loading"])),
+		Warnings: []ImageWarning{},
+	}
+	img.Alt, img.HasAlt = attrs["alt"]
+	img.Alt = collapseWS(img.Alt)
+
+	img.Src = strings.TrimSpace(attrs["src"])
+
+	if c.picture != nil {
+		img.Sources = *c.picture
+	}
+
+	if img.URL() == "" {
+		img.Warnings = append(img.Warnings, ImageWarning{Code: "missing_src", Message: "The image has no src or srcset"})
+	}
+
+	if img.Width == "" || img.Height == "" {
+		img.Warnings = append(img.Warnings, ImageWarning{Code: "missing_dimensions", Message: "The image has no width and height, the layout may shift while it loads"})
+	}
+
+	c.images = append(c.images, img)
+}
+
+// result returns the images with their URLs resolved
+func (c *imageCollector) result() []Image {
+	for i := range c.images {
+		img := &c.images[i]
+		if img.Src != "" {
+			img.Src = c.doc.resolve(img.Src)
+		}
+		c.resolveSrcset(img.Srcset)
+		for _, source := range img.Sources {
+			c.resolveSrcset(source.Srcset)
+		}
+	}
+
+	return c.images
+}
+
+func (c *imageCollector) resolveSrcset(candidates []SrcsetCandidate) {
+	for i := range candidates {
+		candidates[i].URL = c.doc.resolve(candidates[i].URL)
+	}
+}
+
+// srcset parses the candidates of a srcset attribute. URLs may contain
+// commas, so candidates are split at the whitespace after their URL first.
+func (c *imageCollector) srcset(value string) []SrcsetCandidate {
+	candidates := []SrcsetCandidate{}
+
+	for value = strings.TrimLeft(value, " \t\n\r\f,"); value != ""; value = strings.TrimLeft(value, " \t\n\r\f,") {
+		end := strings.IndexAny(value, " \t\n\r\f")
+		if end < 0 {
+			end = len(value)
+		}
+		rawURL := value[:end]
+		value = value[end:]
+
+		descriptor := ""
+		if strings.HasSuffix(rawURL, ",") {
+			rawURL = strings.TrimRight(rawURL, ",")
+		} else if comma := strings.IndexByte(value, ','); comma >= 0 {
+			descriptor, value = value[:comma], value[comma+1:]
+		} else {
+			descriptor, value = value, ""
+		}
+
+		candidates = append(candidates, SrcsetCandidate{URL: rawURL, Descriptor: collapseWS(descriptor)})
+	}
+
+	return candidates
+}
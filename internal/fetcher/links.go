@@ -0,0 +1,381 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// LinkType tells where an anchor leads relative to the page it was found on
+type LinkType string
+
+const (
+	LinkTypeSamePage   LinkType = "same-page"
+	LinkTypeSameHost   LinkType = "same-host"
+	LinkTypeSameSite   LinkType = "same-site"
+	LinkTypeExternal   LinkType = "external"
+	LinkTypeMailto     LinkType = "mailto"
+	LinkTypeTel        LinkType = "tel"
+	LinkTypeJavascript LinkType = "javascript"
+	LinkTypeData       LinkType = "data"
+	LinkTypeOther      LinkType = "other"
+)
+
+// Anchor is a link of a page. URL is resolved against the base URL of the
+// document, Href is the value of the attribute. External compares hosts, not
+// registrable domains: it is set for every link leaving the host of the page,
+// including same-site links to other subdomains, which Type tells apart from
+// links to other sites. Text is the whitespace collapsed content of the link
+// and Region the innermost landmark around it.
+type Anchor struct {
+	External  bool          `json:"external"`
+	URL       string        `json:"url"`
+	Href      string        `json:"href,omitempty"`
+	Type      LinkType      `json:"type,omitempty"`
+	Text      string        `json:"text,omitempty"`
+	Title     string        `json:"title,omitempty"`
+	AriaLabel string        `json:"aria_label,omitempty"`
+	Rel       []string      `json:"rel,omitempty"`
+	Target    string        `json:"target,omitempty"`
+	Hreflang  string        `json:"hreflang,omitempty"`
+	Region    Region        `json:"region,omitempty"`
+	Warnings  []LinkWarning `json:"warnings,omitempty"`
+}
+
+// HasRel reports whether the link has the given rel value, like nofollow,
+// sponsored or ugc
+func (a Anchor) HasRel(rel string) bool {
+	return slices.Contains(a.Rel, rel)
+}
+
+// Checkable reports whether the anchor leads to another web page that can be
+// requested. Anchors without a type are treated as web links.
+func (a Anchor) Checkable() bool {
+	switch a.Type {
+	case "", LinkTypeSameHost, LinkTypeSameSite, LinkTypeExternal:
+		return true
+	default:
+		return false
+	}
+}
+
+// documentURL resolves the references of a document. The first <base href>
+// of the document replaces the document URL as base, wherever it appears.
+type documentURL struct {
+	page *url.URL
+	base *url.URL
+}
+
+func newDocumentURL(pageURL string) *documentURL {
+	page, _ := url.Parse(pageURL)
+
+	return &documentURL{page: page}
+}
+
+// baseTag sets the base URL of the document from its first <base href>
+func (d *documentURL) baseTag(tok html.Token) {
+	href, ok := attrMap(tok)["href"]
+	if !ok || d.base != nil || d.page == nil {
+		return
+	}
+
+	if base, err := d.page.Parse(strings.TrimSpace(href)); err == nil {
+		d.base = base
+	}
+}
+
+// baseURL returns the URL references of the document are resolved against
+func (d *documentURL) baseURL() *url.URL {
+	if d.base != nil {
+		return d.base
+	}
+
+	return d.page
+}
+
+// resolve resolves a reference against the base URL. Fragments are dropped,
+// references that can not be parsed are kept as they are.
+func (d *documentURL) resolve(ref string) string {
+	u, ok := d.parse(ref)
+	if !ok {
+		return strings.TrimSpace(ref)
+	}
+	u.Fragment = ""
+
+	return u.String()
+}
+
+func (d *documentURL) parse(ref string) (*url.URL, bool) {
+	base := d.baseURL()
+	if base == nil {
+		return nil, false
+	}
+
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, false
+	}
+
+	return u, true
+}
+
+// anchor resolves and classifies a link
+func (d *documentURL) anchor(href string) Anchor {
+	a := Anchor{Href: href, URL: strings.TrimSpace(href), Type: LinkTypeOther}
+
+	u, ok := d.parse(href)
+	if !ok {
+		return a
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "mailto":
+		a.Type = LinkTypeMailto
+		return a
+	case "tel":
+		a.Type = LinkTypeTel
+		return a
+	case "javascript":
+		a.Type = LinkTypeJavascript
+		return a
+	case "data":
+		a.Type = LinkTypeData
+		return a
+	case "http", "https":
+	default:
+		return a
+	}
+
+	a.URL = u.String()
+	a.Type = d.linkType(u)
+	a.External = a.Type == LinkTypeSameSite || a.Type == LinkTypeExternal
+
+	return a
+}
+
+func (d *documentURL) linkType(u *url.URL) LinkType {
+	if d.page == nil {
+		return LinkTypeExternal
+	}
+
+	target, page := *u, *d.page
+	target.Fragment, page.Fragment = "", ""
+	if target.String() == page.String() {
+		return LinkTypeSamePage
+	}
+
+	if strings.EqualFold(u.Host, d.page.Host) {
+		return LinkTypeSameHost
+	}
+
+	if site := registrableDomain(u.Hostname()); site != "" && site == registrableDomain(d.page.Hostname()) {
+		return LinkTypeSameSite
+	}
+
+	return LinkTypeExternal
+}
+
+// registrableDomain returns the domain a host was registered under, like
+// example.co.uk for shop.example.co.uk. IP addresses and public suffixes have
+// none.
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return ""
+	}
+
+	return domain
+}
+
+// Region is the landmark of a page an anchor was found in
+type Region string
+
+const (
+	RegionNav    Region = "nav"
+	RegionHeader Region = "header"
+	RegionMain   Region = "main"
+	RegionAside  Region = "aside"
+	RegionFooter Region = "footer"
+	// RegionOther is any place outside of a landmark
+	RegionOther Region = "other"
+)
+
+// landmarkTags are the elements forming a landmark
+var landmarkTags = map[string]Region{
+	"nav":    RegionNav,
+	"header": RegionHeader,
+	"main":   RegionMain,
+	"aside":  RegionAside,
+	"footer": RegionFooter,
+}
+
+// landmarkRoles are the ARIA roles of the landmarks
+var landmarkRoles = map[string]Region{
+	"navigation":    RegionNav,
+	"banner":        RegionHeader,
+	"main":          RegionMain,
+	"complementary": RegionAside,
+	"contentinfo":   RegionFooter,
+}
+
+// genericLinkTexts say nothing about where a link leads
+var genericLinkTexts = map[string]bool{
+	"click here": true, "here": true, "click": true, "more": true, "read more": true,
+	"learn more": true, "link": true, "this link": true, "this": true, "details": true,
+	"more info": true, "continue": true, "go": true,
+}
+
+// LinkWarning is a problem of an anchor found without requesting it
+type LinkWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// anchorCollector gathers the links of a page with their text and context.
+// They are resolved once the whole document, including its base URL, was
+// read.
+type anchorCollector struct {
+	doc     *documentURL
+	anchors []*Anchor
+	// open is the <a> whose content is being read
+	open *Anchor
+	// landmarks are the open landmarks, innermost last
+	landmarks []Region
+}
+
+// Token implements PageExtractor
+func (c *anchorCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	c.landmark(w, tok)
+
+	switch tok.Data {
+	case "a", "area":
+		c.anchorTag(w, tok)
+	case "img":
+		c.imgTag(tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *anchorCollector) Result(r *FetchResult) {
+	r.Anchors = c.result()
+}
+
+// landmark tracks the landmark opened by an element, if any
+func (c *anchorCollector) landmark(w Walker, tok html.Token) {
+	region, ok := landmarkTags[tok.Data]
+	if attr, hasRole := findAttr(tok, "role"); hasRole {
+		if r, isLandmark := landmarkRoles[ariaRole(attr.Val)]; isLandmark {
+			region, ok = r, true
+		}
+	}
+	if !ok {
+		return
+	}
+
+	c.landmarks = append(c.landmarks, region)
+	n := len(c.landmarks)
+	w.WhenClosedOnly(func() {
+		c.landmarks = c.landmarks[:n-1]
+	})
+}
+
+// anchorTag collects an <a> or <area> with an href. The text of a link is its
+// content including the alt text of its images, the text of an area is its
+// alt text.
+func (c *anchorCollector) anchorTag(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+	href, ok := attrs["href"]
+	if !ok {
+		return
+	}
+
+	a := &Anchor{
+		Href:      href,
+		Title:     collapseWS(attrs["title"]),
+		AriaLabel: collapseWS(attrs["aria-label"]),
+		Rel:       strings.Fields(strings.ToLower(attrs["rel"])),
+		Target:    strings.TrimSpace(attrs["target"]),
+		Hreflang:  strings.TrimSpace(attrs["hreflang"]),
+		Region:    RegionOther,
+	}
+	if n := len(c.landmarks); n > 0 {
+		a.Region = c.landmarks[n-1]
+	}
+	c.anchors = append(c.anchors, a)
+
+	if tok.Data == "area" {
+		a.Text = collapseWS(attrs["alt"])
+		return
+	}
+
+	c.open = a
+	w.WhenClosed(func(text string) {
+		a.Text = collapseWS(text + " " + a.Text)
+		if c.open == a {
+			c.open = nil
+		}
+	})
+}
+
+// imgTag adds the alt text of an image to the text of the link around it
+func (c *anchorCollector) imgTag(tok html.Token) {
+	if c.open == nil {
+		return
+	}
+
+	if alt, ok := attrMap(tok)["alt"]; ok {
+		c.open.Text = collapseWS(c.open.Text + " " + alt)
+	}
+}
+
+// result returns every link once, in the order of their first occurrence
+func (c *anchorCollector) result() []Anchor {
+	var anchors []Anchor
+	seen := map[string]struct{}{}
+
+	for _, a := range c.anchors {
+		resolved := c.doc.anchor(a.Href)
+		if _, ok := seen[resolved.URL]; ok {
+			continue
+		}
+		seen[resolved.URL] = struct{}{}
+
+		a.URL, a.Type, a.External = resolved.URL, resolved.Type, resolved.External
+		a.Warnings = linkWarnings(a)
+		anchors = append(anchors, *a)
+	}
+
+	return anchors
+}
+
+// linkWarnings reports links without a name, links named after the click
+// instead of their target and new windows able to control the page
+func linkWarnings(a *Anchor) []LinkWarning {
+	warnings := []LinkWarning{}
+
+	name := a.Text
+	if name == "" {
+		name = a.AriaLabel
+	}
+
+	switch {
+	case name == "":
+		warnings = append(warnings, LinkWarning{Code: "empty_text", Message: "The link has no text"})
+	case genericLinkTexts[strings.Trim(strings.ToLower(name), ".!:>» ")]:
+		warnings = append(warnings, LinkWarning{Code: "generic_text", Message: fmt.Sprintf("The link text %q does not describe where the link leads", name)})
+	}
+
+	if strings.EqualFold(a.Target, "_blank") && a.Checkable() && !a.HasRel("noopener") && !a.HasRel("noreferrer") {
+		warnings = append(warnings, LinkWarning{Code: "unsafe_target_blank", Message: "The link opens a new window without rel=noopener"})
+	}
+
+	return warnings
+}
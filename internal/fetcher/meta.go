@@ -0,0 +1,227 @@
+package fetcher
+
+import (
+	"fmt"
+	"maps"
+	"mime"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Meta holds the metadata a page declares for search engines, social
+// networks and browsers
+type Meta struct {
+	Description string            `json:"description"`
+	Robots      string            `json:"robots"`
+	Canonical   string            `json:"canonical"`
+	Viewport    string            `json:"viewport"`
+	Charset     string            `json:"charset"`
+	OpenGraph   map[string]string `json:"open_graph"`
+	Twitter     map[string]string `json:"twitter"`
+	Warnings    []MetaWarning     `json:"warnings"`
+}
+
+// MetaWarning is a missing or duplicated declaration
+type MetaWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// requiredOpenGraph are the properties every Open Graph object needs
+var requiredOpenGraph = []string{"og:title", "og:type", "og:image", "og:url"}
+
+// repeatableOpenGraph are property prefixes that may be declared more than
+// once, like the images of a page
+var repeatableOpenGraph = []string{"og:image", "og:video", "og:audio", "og:locale:alternate"}
+
+// metaCollector gathers the meta and link declarations of a page
+type metaCollector struct {
+	doc    *documentURL
+	meta   Meta
+	counts map[string]int
+}
+
+func newMetaCollector(doc *documentURL) *metaCollector {
+	return &metaCollector{
+		doc:    doc,
+		meta:   Meta{OpenGraph: map[string]string{}, Twitter: map[string]string{}},
+		counts: map[string]int{},
+	}
+}
+
+// Token implements PageExtractor
+func (c *metaCollector) Token(_ Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	switch tok.Data {
+	case "meta":
+		c.metaTag(tok)
+	case "link":
+		c.linkTag(tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *metaCollector) Result(r *FetchResult) {
+	r.Meta = c.result()
+}
+
+func (c *metaCollector) metaTag(tok html.Token) {
+	attrs := attrMap(tok)
+
+	if charset, ok := attrs["charset"]; ok {
+		c.set(&c.meta.Charset, "charset", charset)
+		return
+	}
+
+	content := strings.TrimSpace(attrs["content"])
+
+	if strings.EqualFold(attrs["http-equiv"], "content-type") {
+		if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+			c.set(&c.meta.Charset, "charset", params["charset"])
+		}
+		return
+	}
+
+	// Open Graph uses property, Twitter Cards use name, but pages mix both
+	name := strings.ToLower(strings.TrimSpace(attrs["name"]))
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(attrs["property"]))
+	}
+
+	switch {
+	case name == "description":
+		c.set(&c.meta.Description, name, content)
+	case name == "robots":
+		c.set(&c.meta.Robots, name, content)
+	case name == "viewport":
+		c.set(&c.meta.Viewport, name, content)
+	case strings.HasPrefix(name, "og:"):
+		c.property(c.meta.OpenGraph, name, content)
+	case strings.HasPrefix(name, "twitter:"):
+		c.property(c.meta.Twitter, name, content)
+	}
+}
+
+// linkTag collects a <link rel="canonical"> declaration
+func (c *metaCollector) linkTag(tok html.Token) {
+	attrs := attrMap(tok)
+
+	for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		if rel == "canonical" {
+			c.set(&c.meta.Canonical, "canonical", strings.TrimSpace(attrs["href"]))
+		}
+	}
+}
+
+// set keeps the first value of a declaration and counts every occurrence
+func (c *metaCollector) set(field *string, key, value string) {
+	c.counts[key]++
+	if c.counts[key] == 1 {
+		*field = value
+	}
+}
+
+func (c *metaCollector) property(props map[string]string, key, value string) {
+	c.counts[key]++
+	if _, ok := props[key]; !ok {
+		props[key] = value
+	}
+}
+
+// result returns the collected metadata with a warning for every missing or
+// duplicated declaration. Open Graph, Twitter Cards and the charset are
+// optional, the charset is usually sent in the Content-Type header.
+func (c *metaCollector) result() Meta {
+	m := c.meta
+	m.Warnings = []MetaWarning{}
+
+	if m.Canonical != "" {
+		m.Canonical = c.doc.resolve(m.Canonical)
+	}
+
+	for _, d := range []struct {
+		key   string
+		label string
+		// required declarations are reported when missing
+		required bool
+	}{
+		{"description", "meta description", true},
+		{"canonical", "canonical link", true},
+		{"viewport", "viewport declaration", true},
+		{"charset", "charset declaration", false},
+		{"robots", "robots meta tag", false},
+	} {
+		switch n := c.counts[d.key]; {
+		case n == 0 && d.required:
+			m.Warnings = append(m.Warnings, MetaWarning{Code: "missing_" + d.key, Message: fmt.Sprintf("The page has no %s", d.label)})
+		case n > 1:
+			m.Warnings = append(m.Warnings, MetaWarning{Code: "duplicate_" + d.key, Message: fmt.Sprintf("The page declares %d %ss", n, d.label)})
+		}
+	}
+
+	for _, directive := range strings.Split(strings.ToLower(m.Robots), ",") {
+		if directive = strings.TrimSpace(directive); directive == "noindex" || directive == "none" {
+			m.Warnings = append(m.Warnings, MetaWarning{Code: "noindex", Message: "The page asks search engines not to index it"})
+		}
+	}
+
+	if len(m.OpenGraph) > 0 {
+		for _, key := range requiredOpenGraph {
+			if _, ok := m.OpenGraph[key]; !ok {
+				m.Warnings = append(m.Warnings, MetaWarning{Code: "missing_og_property", Message: fmt.Sprintf("The Open Graph property %s is missing", key)})
+			}
+		}
+	}
+
+	if len(m.Twitter) > 0 {
+		if _, ok := m.Twitter["twitter:card"]; !ok {
+			m.Warnings = append(m.Warnings, MetaWarning{Code: "missing_twitter_card", Message: "The Twitter Card properties have no twitter:card declaration"})
+		}
+	}
+
+	m.Warnings = append(m.Warnings, c.duplicateProperties(m.OpenGraph, "duplicate_og_property")...)
+	m.Warnings = append(m.Warnings, c.duplicateProperties(m.Twitter, "duplicate_twitter_property")...)
+
+	return m
+}
+
+func (c *metaCollector) duplicateProperties(props map[string]string, code string) []MetaWarning {
+	var warnings []MetaWarning
+
+	for _, key := range slices.Sorted(maps.Keys(props)) {
+		if c.counts[key] > 1 && !repeatable(key) {
+			warnings = append(warnings, MetaWarning{Code: code, Message: fmt.Sprintf("The property %s is declared %d times", key, c.counts[key])})
+		}
+	}
+
+	return warnings
+}
+
+func repeatable(key string) bool {
+	for _, prefix := range repeatableOpenGraph {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attrMap returns the attributes of a token keyed by their lower case name.
+// The first of duplicated attributes wins, like in browsers.
+func attrMap(tok html.Token) map[string]string {
+	attrs := make(map[string]string, len(tok.Attr))
+	for _, a := range tok.Attr {
+		key := strings.ToLower(a.Key)
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = a.Val
+		}
+	}
+
+	return attrs
+}
@@ -0,0 +1,126 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PingStrategy selects the requests used to check a link
+type PingStrategy string
+
+const (
+	// PingStrategyHead sends a HEAD request and falls back to a limited GET
+	// when the server does not handle HEAD properly
+	PingStrategyHead PingStrategy = "head"
+	// PingStrategyGet always sends a limited GET request
+	PingStrategyGet PingStrategy = "get"
+)
+
+// pingDrainLimit is the number of body bytes read from a GET response so the
+// connection can be reused
+const pingDrainLimit = 8 << 10
+
+// headFallbackStatus holds the HEAD response codes of servers that do not
+// support HEAD or answer it differently than GET
+var headFallbackStatus = map[int]struct{}{
+	http.StatusBadRequest:       {},
+	http.StatusForbidden:        {},
+	http.StatusMethodNotAllowed: {},
+	http.StatusNotAcceptable:    {},
+	http.StatusNotImplemented:   {},
+}
+
+// PingOption is a function that configures a single ping
+type PingOption func(*pingConfig)
+
+type pingConfig struct {
+	strategy PingStrategy
+}
+
+// WithPingStrategy sets the strategy used for a ping, HEAD first by default
+func WithPingStrategy(strategy PingStrategy) PingOption {
+	return func(c *pingConfig) {
+		c.strategy = strategy
+	}
+}
+
+// PingResult describes the response of a pinged URL
+type PingResult struct {
+	Method     string
+	StatusCode int
+	FinalURL   string
+	Redirects  RedirectChain
+}
+
+// Ping
+// Requests the given url without parsing it. The result is returned whenever
+// the server responded, together with an error for non 2xx status codes.
+func (f fetcher) Ping(ctx context.Context, url string, opts ...PingOption) (*PingResult, error) {
+	config := pingConfig{strategy: PingStrategyHead}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.strategy == PingStrategyGet {
+		return f.ping(ctx, http.MethodGet, url)
+	}
+
+	res, err := f.ping(ctx, http.MethodHead, url)
+	if !shouldFallback(res, err) {
+		return res, err
+	}
+
+	f.logger.Debug("HEAD not supported, falling back to GET", "url", url)
+
+	return f.ping(ctx, http.MethodGet, url)
+}
+
+// shouldFallback reports whether a HEAD outcome is not trustworthy and the
+// link has to be checked with GET
+func shouldFallback(res *PingResult, err error) bool {
+	if res != nil {
+		_, ok := headFallbackStatus[res.StatusCode]
+		return ok
+	}
+
+	return ClassifyError(err) == ErrorClassUnknown
+}
+
+func (f fetcher) ping(ctx context.Context, method, url string) (*PingResult, error) {
+	ctx, rec := withRedirectRecorder(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	setUserAgent(req, f.userAgent)
+
+	start := time.Now()
+	resp, err := f.httpClient.Do(req)
+	observeAttempt(ctx, start)
+	if err != nil {
+		if resp != nil {
+			return &PingResult{Method: method, StatusCode: resp.StatusCode, FinalURL: resp.Request.URL.String(), Redirects: rec.chain(f.longRedirects)}, err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, pingDrainLimit))
+
+	result := &PingResult{
+		Method:     method,
+		StatusCode: resp.StatusCode,
+		FinalURL:   resp.Request.URL.String(),
+		Redirects:  rec.chain(f.longRedirects),
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("unexpected status code %s: %w", resp.Status, newStatusError(resp))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+var (
+	ErrRedirectLoop     = errors.New("redirect loop")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+const (
+	defaultMaxRedirects  = 10
+	defaultLongRedirects = 3
+)
+
+// RedirectHop is a single redirect response of a request
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// RedirectChain holds the redirects followed by a request. Long is set when
+// the chain has more hops than the configured limit, Downgrade when one of
+// the hops redirects from https to http.
+type RedirectChain struct {
+	Hops      []RedirectHop `json:"hops"`
+	Loop      bool          `json:"loop"`
+	Long      bool          `json:"long"`
+	Downgrade bool          `json:"downgrade"`
+}
+
+type redirectRecorderKey struct{}
+
+// redirectRecorder collects the hops of a single request, it is filled by
+// checkRedirect through the request context
+type redirectRecorder struct {
+	hops []RedirectHop
+	loop bool
+}
+
+func withRedirectRecorder(ctx context.Context) (context.Context, *redirectRecorder) {
+	rec := &redirectRecorder{}
+	return context.WithValue(ctx, redirectRecorderKey{}, rec), rec
+}
+
+// chain builds the RedirectChain of the recorded hops
+func (rec *redirectRecorder) chain(longLimit int) RedirectChain {
+	c := RedirectChain{Hops: rec.hops, Loop: rec.loop, Long: len(rec.hops) > longLimit}
+
+	for _, hop := range rec.hops {
+		from, err := url.Parse(hop.URL)
+		if err != nil || from.Scheme != "https" {
+			continue
+		}
+		if to, err := from.Parse(hop.Location); err == nil && to.Scheme == "http" {
+			c.Downgrade = true
+		}
+	}
+
+	return c
+}
+
+// checkRedirect is the http.Client redirect policy of the fetcher. It records
+// every hop and stops on loops and on chains longer than maxRedirects.
+func (f fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	rec, _ := req.Context().Value(redirectRecorderKey{}).(*redirectRecorder)
+	if rec == nil {
+		rec = &redirectRecorder{}
+	}
+
+	if req.Response != nil {
+		rec.hops = append(rec.hops, RedirectHop{
+			URL:        via[len(via)-1].URL.String(),
+			StatusCode: req.Response.StatusCode,
+			Location:   req.Response.Header.Get("Location"),
+		})
+	}
+
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			rec.loop = true
+			return ErrRedirectLoop
+		}
+	}
+
+	if len(via) >= f.maxRedirects {
+		return ErrTooManyRedirects
+	}
+
+	return nil
+}
+
+// finalURL returns the URL a request starting at start ended on
+func (c RedirectChain) finalURL(start string) string {
+	if len(c.Hops) == 0 {
+		return start
+	}
+
+	last := c.Hops[len(c.Hops)-1]
+	from, err := url.Parse(last.URL)
+	if err != nil {
+		return start
+	}
+
+	to, err := from.Parse(last.Location)
+	if err != nil {
+		return start
+	}
+
+	return to.String()
+}
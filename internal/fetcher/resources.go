@@ -0,0 +1,164 @@
+package fetcher
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ResourceKind is what a page loads a resource as
+type ResourceKind string
+
+const (
+	ResourceScript     ResourceKind = "script"
+	ResourceStylesheet ResourceKind = "stylesheet"
+	ResourceFont       ResourceKind = "font"
+	ResourcePreload    ResourceKind = "preload"
+	ResourceIcon       ResourceKind = "icon"
+	ResourceManifest   ResourceKind = "manifest"
+	ResourceIframe     ResourceKind = "iframe"
+	ResourceMedia      ResourceKind = "media"
+)
+
+// Resource is a file a page loads besides its images, like scripts,
+// stylesheets, fonts, frames and media. URL is resolved against the base URL
+// of the document.
+type Resource struct {
+	Kind ResourceKind `json:"kind"`
+	URL  string       `json:"url"`
+	Tag  string       `json:"tag"`
+	Type string       `json:"type,omitempty"`
+}
+
+// Blocking reports whether the page renders or behaves wrong without the
+// resource
+func (r Resource) Blocking() bool {
+	return r.Kind == ResourceScript || r.Kind == ResourceStylesheet
+}
+
+// resourceCollector gathers the resources of a page
+type resourceCollector struct {
+	doc       *documentURL
+	resources []Resource
+	// media is set while an <audio> or <video> is open
+	media bool
+}
+
+func newResourceCollector(doc *documentURL) *resourceCollector {
+	return &resourceCollector{doc: doc}
+}
+
+// Token implements PageExtractor
+func (c *resourceCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	switch tok.Data {
+	case "script":
+		c.scriptTag(tok)
+	case "link":
+		c.linkTag(tok)
+	case "iframe":
+		c.iframeTag(tok)
+	case "audio", "video":
+		c.mediaTag(w, tok)
+	case "source", "track":
+		c.sourceTag(tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *resourceCollector) Result(r *FetchResult) {
+	r.Resources = c.result()
+}
+
+// scriptTag collects the src of a <script>
+func (c *resourceCollector) scriptTag(tok html.Token) {
+	attrs := attrMap(tok)
+	c.add(ResourceScript, tok.Data, attrs["src"], attrs["type"])
+}
+
+// linkTag collects stylesheets, preloads, icons and manifests of a <link>
+func (c *resourceCollector) linkTag(tok html.Token) {
+	attrs := attrMap(tok)
+
+	for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		switch rel {
+		case "stylesheet":
+			c.add(ResourceStylesheet, tok.Data, attrs["href"], attrs["type"])
+		case "preload", "modulepreload":
+			kind := ResourcePreload
+			switch strings.ToLower(attrs["as"]) {
+			case "font":
+				kind = ResourceFont
+			case "script":
+				kind = ResourceScript
+			case "style":
+				kind = ResourceStylesheet
+			}
+			if rel == "modulepreload" {
+				kind = ResourceScript
+			}
+			c.add(kind, tok.Data, attrs["href"], attrs["type"])
+		case "icon", "apple-touch-icon", "mask-icon":
+			c.add(ResourceIcon, tok.Data, attrs["href"], attrs["type"])
+		case "manifest":
+			c.add(ResourceManifest, tok.Data, attrs["href"], attrs["type"])
+		}
+	}
+}
+
+// iframeTag collects the src of an <iframe>
+func (c *resourceCollector) iframeTag(tok html.Token) {
+	c.add(ResourceIframe, tok.Data, attrMap(tok)["src"], "")
+}
+
+// mediaTag collects the src and poster of an <audio> or <video> and tracks it
+// until it is closed, so its sources are collected as well
+func (c *resourceCollector) mediaTag(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+	c.add(ResourceMedia, tok.Data, attrs["src"], "")
+	c.add(ResourceMedia, tok.Data, attrs["poster"], "")
+
+	c.media = true
+	w.WhenClosedOnly(func() {
+		c.media = false
+	})
+}
+
+// sourceTag collects a <source> or <track> of an open <audio> or <video>
+func (c *resourceCollector) sourceTag(tok html.Token) {
+	if !c.media {
+		return
+	}
+
+	attrs := attrMap(tok)
+	c.add(ResourceMedia, tok.Data, attrs["src"], attrs["type"])
+}
+
+func (c *resourceCollector) add(kind ResourceKind, tag, ref, typ string) {
+	if ref = strings.TrimSpace(ref); ref == "" {
+		return
+	}
+
+	c.resources = append(c.resources, Resource{Kind: kind, URL: ref, Tag: tag, Type: strings.TrimSpace(typ)})
+}
+
+// result returns the resources with their URLs resolved, every URL once
+func (c *resourceCollector) result() []Resource {
+	resources := []Resource{}
+	seen := map[string]struct{}{}
+
+	for _, r := range c.resources {
+		r.URL = c.doc.resolve(r.URL)
+		if _, ok := seen[r.URL]; ok {
+			continue
+		}
+		seen[r.URL] = struct{}{}
+
+		resources = append(resources, r)
+	}
+
+	return resources
+}
@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+// RetryPolicy configures how transient failures are retried. Jitter is the
+// fraction of every delay that is randomized to spread out retries.
+type RetryPolicy struct {
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	Jitter          float64
+	RetryableStatus []int
+}
+
+// DefaultRetryPolicy returns a policy retrying timeouts, dropped connections
+// and throttling or gateway errors up to three times
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		BaseDelay:       200 * time.Millisecond,
+		MaxDelay:        2 * time.Second,
+		Jitter:          0.2,
+		RetryableStatus: util.DefaultRetryStatusCodes(),
+	}
+}
+
+type retryFetcher struct {
+	next   Fetcher
+	policy RetryPolicy
+	logger *slog.Logger
+}
+
+// NewRetryFetcher wraps a Fetcher so transient failures of Fetch and Ping are
+// retried with exponential backoff according to the policy
+func NewRetryFetcher(f Fetcher, logger *slog.Logger, policy RetryPolicy) Fetcher {
+	return retryFetcher{next: f, policy: policy, logger: logger}
+}
+
+func (r retryFetcher) Fetch(ctx context.Context, url string, opts ...FetchOption) (*FetchResult, error) {
+	var result *FetchResult
+
+	err := r.retry(ctx, url, func() error {
+		var err error
+		result, err = r.next.Fetch(ctx, url, opts...)
+		return err
+	})
+
+	return result, err
+}
+
+func (r retryFetcher) Ping(ctx context.Context, url string, opts ...PingOption) (*PingResult, error) {
+	var result *PingResult
+
+	err := r.retry(ctx, url, func() error {
+		var err error
+		result, err = r.next.Ping(ctx, url, opts...)
+		return err
+	})
+
+	return result, err
+}
+
+// retry calls fn until it succeeds, fails permanently or the attempts are used
+func (r retryFetcher) retry(ctx context.Context, url string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay, ok := r.delay(attempt, err)
+		if !ok {
+			return err
+		}
+
+		r.logger.Info("Retrying request", "url", url, "attempt", attempt, "delay", delay, "error", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt and whether err is
+// worth retrying at all. A Retry-After longer than the maximum delay is
+// respected by giving up instead of retrying early.
+func (r retryFetcher) delay(attempt int, err error) (time.Duration, bool) {
+	var retryAfter time.Duration
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if !r.isRetryableStatus(statusErr.StatusCode) {
+			return 0, false
+		}
+		retryAfter = statusErr.RetryAfter
+	} else {
+		switch ClassifyError(err) {
+		case ErrorClassTimeout, ErrorClassConnectionRefused, ErrorClassUnknown:
+		default:
+			return 0, false
+		}
+	}
+
+	if retryAfter > r.policy.MaxDelay {
+		return 0, false
+	}
+
+	d := r.policy.BaseDelay << (attempt - 1)
+	if d > r.policy.MaxDelay || d <= 0 {
+		d = r.policy.MaxDelay
+	}
+	if r.policy.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * r.policy.Jitter * float64(d))
+	}
+
+	return max(d, retryAfter), true
+}
+
+func (r retryFetcher) isRetryableStatus(code int) bool {
+	for _, c := range r.policy.RetryableStatus {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
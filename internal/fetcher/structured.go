@@ -0,0 +1,348 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	FormatJSONLD    = "json-ld"
+	FormatMicrodata = "microdata"
+)
+
+// StructuredData holds the schema.org items of a page and the problems found
+// while reading and validating them
+type StructuredData struct {
+	Items  []*SchemaItem         `json:"items"`
+	Errors []StructuredDataError `json:"errors"`
+}
+
+// SchemaItem is a schema.org item declared as JSON-LD or microdata. Types are
+// stored without the schema.org prefix.
+type SchemaItem struct {
+	Format     string                   `json:"format"`
+	Types      []string                 `json:"types"`
+	ID         string                   `json:"id,omitempty"`
+	Properties map[string][]SchemaValue `json:"properties"`
+}
+
+// SchemaValue is either a text or a nested item
+type SchemaValue struct {
+	Text string      `json:"text,omitempty"`
+	Item *SchemaItem `json:"item,omitempty"`
+}
+
+// StructuredDataError is an unreadable block or an item missing a required
+// property
+type StructuredDataError struct {
+	Format  string `json:"format"`
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message"`
+}
+
+func newSchemaItem(format string) *SchemaItem {
+	return &SchemaItem{Format: format, Properties: map[string][]SchemaValue{}}
+}
+
+// Has reports whether the item has a non-empty value for the property
+func (i *SchemaItem) Has(property string) bool {
+	for _, v := range i.Properties[property] {
+		if v.Item != nil || v.Text != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is reports whether the item has the given type
+func (i *SchemaItem) Is(typ string) bool {
+	return slices.Contains(i.Types, typ)
+}
+
+func (i *SchemaItem) add(property string, v SchemaValue) {
+	i.Properties[property] = append(i.Properties[property], v)
+}
+
+// schemaType strips the vocabulary of a type, like https://schema.org/Product
+// or schema:Product
+func schemaType(t string) string {
+	t = strings.TrimSpace(t)
+	if i := strings.LastIndexAny(t, "/:#"); i >= 0 {
+		t = t[i+1:]
+	}
+
+	return t
+}
+
+// schemaRule lists the properties an item of a type needs. Every group of
+// alternatives needs at least one of its properties.
+type schemaRule [][]string
+
+var schemaRules = map[string]schemaRule{
+	"Product":        {{"name"}, {"offers", "review", "aggregateRating"}},
+	"Offer":          {{"price", "priceSpecification"}, {"priceCurrency", "priceSpecification"}},
+	"AggregateOffer": {{"lowPrice"}, {"priceCurrency"}},
+	"BreadcrumbList": {{"itemListElement"}},
+	"ListItem":       {{"position"}, {"name", "item"}},
+	"Organization":   {{"name"}, {"url"}},
+}
+
+// structuredDataCollector gathers JSON-LD blocks and microdata items
+type structuredDataCollector struct {
+	data StructuredData
+	// scopes are the open microdata items, innermost last
+	scopes []*SchemaItem
+}
+
+func newStructuredDataCollector() *structuredDataCollector {
+	return &structuredDataCollector{data: StructuredData{Items: []*SchemaItem{}, Errors: []StructuredDataError{}}}
+}
+
+// Token implements PageExtractor
+func (c *structuredDataCollector) Token(w Walker, tt html.TokenType, tok html.Token) {
+	if tt == html.DoctypeToken {
+		return
+	}
+
+	c.microdata(w, tok)
+	if tok.Data == "script" {
+		c.script(w, tok)
+	}
+}
+
+// Result implements PageExtractor
+func (c *structuredDataCollector) Result(r *FetchResult) {
+	r.StructuredData = c.result()
+}
+
+func (c *structuredDataCollector) script(w Walker, tok html.Token) {
+	attr, ok := findAttr(tok, "type")
+	if !ok || !strings.EqualFold(strings.TrimSpace(attr.Val), "application/ld+json") {
+		return
+	}
+
+	w.WhenClosed(c.jsonLD)
+}
+
+func (c *structuredDataCollector) jsonLD(text string) {
+	var doc any
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		c.data.Errors = append(c.data.Errors, StructuredDataError{Format: FormatJSONLD, Message: fmt.Sprintf("invalid JSON-LD: %s", err.Error())})
+		return
+	}
+
+	for _, node := range jsonLDNodes(doc) {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			c.data.Errors = append(c.data.Errors, StructuredDataError{Format: FormatJSONLD, Message: "JSON-LD node is not an object"})
+			continue
+		}
+
+		c.data.Items = append(c.data.Items, jsonLDItem(obj))
+	}
+}
+
+// jsonLDNodes returns the top level nodes of a JSON-LD document, which may
+// be a single node, an array or a @graph
+func jsonLDNodes(doc any) []any {
+	switch v := doc.(type) {
+	case []any:
+		var nodes []any
+		for _, n := range v {
+			nodes = append(nodes, jsonLDNodes(n)...)
+		}
+		return nodes
+	case map[string]any:
+		if graph, ok := v["@graph"]; ok {
+			return jsonLDNodes(graph)
+		}
+	}
+
+	return []any{doc}
+}
+
+func jsonLDItem(obj map[string]any) *SchemaItem {
+	item := newSchemaItem(FormatJSONLD)
+
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		switch key {
+		case "@type":
+			for _, t := range jsonLDValues(obj[key]) {
+				if s, ok := t.(string); ok {
+					item.Types = append(item.Types, schemaType(s))
+				}
+			}
+		case "@id":
+			item.ID, _ = obj[key].(string)
+		case "@context":
+		default:
+			for _, v := range jsonLDValues(obj[key]) {
+				item.add(key, jsonLDValue(v))
+			}
+		}
+	}
+
+	return item
+}
+
+func jsonLDValues(v any) []any {
+	if values, ok := v.([]any); ok {
+		return values
+	}
+
+	return []any{v}
+}
+
+func jsonLDValue(v any) SchemaValue {
+	switch v := v.(type) {
+	case map[string]any:
+		// Value objects like {"@value": "9.99"} are plain values
+		if value, ok := v["@value"]; ok {
+			return jsonLDValue(value)
+		}
+		return SchemaValue{Item: jsonLDItem(v)}
+	case string:
+		return SchemaValue{Text: v}
+	case float64:
+		return SchemaValue{Text: strconv.FormatFloat(v, 'f', -1, 64)}
+	case bool:
+		return SchemaValue{Text: strconv.FormatBool(v)}
+	default:
+		return SchemaValue{}
+	}
+}
+
+// microdata collects the itemscope and itemprop attributes of an element
+func (c *structuredDataCollector) microdata(w Walker, tok html.Token) {
+	attrs := attrMap(tok)
+
+	_, isScope := attrs["itemscope"]
+	props, hasProp := attrs["itemprop"]
+	if !isScope && !hasProp {
+		return
+	}
+
+	var parent *SchemaItem
+	if n := len(c.scopes); n > 0 {
+		parent = c.scopes[n-1]
+	}
+
+	if !isScope {
+		if parent == nil {
+			return
+		}
+
+		if value, ok := microdataAttrValue(tok.Data, attrs); ok {
+			addProps(parent, props, SchemaValue{Text: value})
+			return
+		}
+
+		w.WhenClosed(func(text string) {
+			addProps(parent, props, SchemaValue{Text: text})
+		})
+		return
+	}
+
+	item := newSchemaItem(FormatMicrodata)
+	item.ID = attrs["itemid"]
+	for _, t := range strings.Fields(attrs["itemtype"]) {
+		item.Types = append(item.Types, schemaType(t))
+	}
+
+	if hasProp && parent != nil {
+		addProps(parent, props, SchemaValue{Item: item})
+	} else {
+		c.data.Items = append(c.data.Items, item)
+	}
+
+	c.scopes = append(c.scopes, item)
+	w.WhenClosedOnly(func() {
+		c.scopes = slices.DeleteFunc(c.scopes, func(i *SchemaItem) bool { return i == item })
+	})
+}
+
+func addProps(item *SchemaItem, props string, v SchemaValue) {
+	for _, name := range strings.Fields(props) {
+		item.add(name, v)
+	}
+}
+
+// microdataAttrValue returns the value of a property that is taken from an
+// attribute instead of the text content of its element
+func microdataAttrValue(tag string, attrs map[string]string) (string, bool) {
+	var attr string
+
+	switch tag {
+	case "meta":
+		attr = "content"
+	case "a", "area", "link":
+		attr = "href"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		if v, ok := attrs["datetime"]; ok {
+			return strings.TrimSpace(v), true
+		}
+		return "", false
+	default:
+		if v, ok := attrs["content"]; ok {
+			return strings.TrimSpace(v), true
+		}
+		return "", false
+	}
+
+	return strings.TrimSpace(attrs[attr]), true
+}
+
+// result validates the collected items and returns them with all errors
+func (c *structuredDataCollector) result() StructuredData {
+	d := c.data
+	for _, item := range d.Items {
+		if len(item.Types) == 0 {
+			d.Errors = append(d.Errors, StructuredDataError{Format: item.Format, Message: "item has no type"})
+		}
+		d.Errors = append(d.Errors, validateSchemaItem(item)...)
+	}
+
+	return d
+}
+
+// validateSchemaItem checks the required properties of an item and of the
+// items nested in it. Nested items may omit their type, like references to
+// another node by @id.
+func validateSchemaItem(item *SchemaItem) []StructuredDataError {
+	var errs []StructuredDataError
+
+	for _, t := range item.Types {
+		for _, alternatives := range schemaRules[t] {
+			if !slices.ContainsFunc(alternatives, item.Has) {
+				errs = append(errs, StructuredDataError{
+					Format:  item.Format,
+					Type:    t,
+					Message: fmt.Sprintf("%s is missing the required property %s", t, strings.Join(alternatives, " or ")),
+				})
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(item.Properties)) {
+		for _, v := range item.Properties[name] {
+			if v.Item != nil {
+				errs = append(errs, validateSchemaItem(v.Item)...)
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,183 @@
+package gate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func testReport() *crawler.BatchReport {
+	child := &crawler.CrawlResult{FetchResult: fetcher.FetchResult{
+		URL:     "https://shop.test/sale",
+		Outline: fetcher.Outline{Headings: []fetcher.Heading{{Level: 1, Text: "Sale"}, {Level: 2, Text: "Sofas"}, {Level: 1, Text: "More sale"}}},
+	}, ImageChecks: []crawler.ImageCheck{
+		{URLCheck: crawler.URLCheck{URL: "https://shop.test/sofa.jpg", Status: crawler.LinkStatusOK}},
+		{URLCheck: crawler.URLCheck{URL: "https://shop.test/chair.jpg", Status: crawler.LinkStatusOK}, Candidates: []crawler.URLCheck{
+			{URL: "https://shop.test/chair-2x.jpg", Status: crawler.LinkStatusBroken},
+		}},
+	}}
+
+	root := &crawler.CrawlResult{
+		FetchResult: fetcher.FetchResult{
+			URL:     "https://shop.test",
+			Title:   "Shop",
+			Outline: fetcher.Outline{Headings: []fetcher.Heading{{Level: 1, Text: "Shop"}}},
+		},
+		LinkChecks: []crawler.LinkCheck{
+			{URLCheck: crawler.URLCheck{URL: "https://shop.test/sale", Status: crawler.LinkStatusOK}},
+			{URLCheck: crawler.URLCheck{URL: "https://shop.test/gone", Status: crawler.LinkStatusBroken}},
+			{URLCheck: crawler.URLCheck{URL: "https://shop.test/old", Status: crawler.LinkStatusBroken}},
+		},
+		ResourceChecks: []crawler.ResourceCheck{
+			{Resource: fetcher.Resource{Kind: fetcher.ResourceStylesheet}, URLCheck: crawler.URLCheck{URL: "https://shop.test/app.css", Status: crawler.LinkStatusBroken}},
+			{Resource: fetcher.Resource{Kind: fetcher.ResourceScript}, URLCheck: crawler.URLCheck{URL: "https://shop.test/app.js", Status: crawler.LinkStatusOK}},
+		},
+		Children: []*crawler.CrawlResult{child},
+	}
+
+	return &crawler.BatchReport{Items: []crawler.BatchItem{
+		{URL: "https://shop.test", Status: crawler.BatchStatusOK, Result: root},
+		{URL: "https://down.test", Status: crawler.BatchStatusFailed, Error: "could not reach to server"},
+	}}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestEvaluate(t *testing.T) {
+	cfg := &Config{Rules: []Rule{
+		{ID: "broken-links", Max: intPtr(1)},
+		{ID: "missing-title"},
+		{ID: "h1-count", Level: LevelWarning},
+	}}
+
+	result := Evaluate(cfg, testReport())
+
+	// One crawl rule, two page rules for two pages and the failed URL
+	assert.Len(t, result.Checks, 6)
+	assert.True(t, result.Failed())
+
+	var violations []string
+	for _, c := range result.Violations() {
+		violations = append(violations, c.Rule+" "+c.URL)
+	}
+	assert.Equal(t, []string{
+		"broken-links https://shop.test",
+		"missing-title https://shop.test/sale",
+		"h1-count https://shop.test/sale",
+		"crawl https://down.test",
+	}, violations)
+
+	assert.Equal(t, "https://shop.test: 2 broken links, at most 1 allowed", result.Violations()[0].Message)
+	assert.Equal(t, LevelWarning, result.Violations()[2].Level)
+
+	// Warnings alone do not fail the gate
+	report := testReport()
+	report.Items = report.Items[:1]
+	result = Evaluate(&Config{Rules: []Rule{{ID: "broken-links", Max: intPtr(2)}, {ID: "h1-count", Level: LevelWarning}}}, report)
+	assert.False(t, result.Failed())
+	assert.Len(t, result.Violations(), 1)
+}
+
+func TestEvaluate_FetchedPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Shop</title></head><body><h1>Shop</h1><a href="/sale">Sale</a><a href="/plain">Plain</a></body></html>`)
+	})
+	mux.HandleFunc("/sale", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Sale</title></head><body><h1>Sale</h1><h2>Sofas</h2><h1><img src="/s.png" alt="More sale"></h1></body></html>`)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Plain</title></head><body><h2>No h1</h2></body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := crawler.NewCrawler(fetcher.NewFetcher(server.Client(), logger, 10<<20), logger, crawler.WithCrawlDepth(2))
+	report := crawler.NewBatchRunner(c, logger).Run(context.Background(), []string{server.URL})
+
+	result := Evaluate(&Config{Rules: []Rule{{ID: "missing-h1"}, {ID: "h1-count"}}}, report)
+
+	var violations []string
+	for _, c := range result.Violations() {
+		violations = append(violations, c.Rule+" "+strings.TrimPrefix(c.URL, server.URL)+" "+strconv.Itoa(c.Value))
+	}
+	assert.ElementsMatch(t, []string{"h1-count /sale 2", "missing-h1 /plain 1"}, violations)
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`{"rules": [{"rule": "broken-links", "max": 5}, {"rule": "missing-h1", "level": "warning"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, *cfg.Rules[0].Max)
+	assert.Equal(t, LevelWarning, cfg.Rules[1].Level)
+
+	for _, invalid := range []string{
+		`{"rules": []}`,
+		`{"rules": [{"rule": "unknown"}]}`,
+		`{"rules": [{"rule": "broken-links", "max": -1}]}`,
+		`{"rules": [{"rule": "broken-links", "level": "fatal"}]}`,
+		`{"rules": [{"rule": "broken-links", "threshold": 1}]}`,
+	} {
+		_, err := LoadConfig(strings.NewReader(invalid))
+		assert.ErrorIs(t, err, ErrInvalidConfig, invalid)
+	}
+
+	assert.NoError(t, DefaultConfig().Validate())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	result := Evaluate(DefaultConfig(), testReport())
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(&buf, result))
+
+	var doc junitTestSuites
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+
+	assert.Len(t, doc.Suites, 2)
+	assert.Equal(t, len(result.Checks), doc.Tests)
+	assert.Equal(t, 4, doc.Failures) // broken links, images and resources and the missing title of the sale page
+	assert.Equal(t, 1, doc.Errors)
+	assert.Equal(t, "https://down.test", doc.Suites[1].Name)
+	assert.Equal(t, "could not reach to server", doc.Suites[1].TestCases[0].Error.Message)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	result := Evaluate(DefaultConfig(), testReport())
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSARIF(&buf, result))
+
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Len(t, log.Runs[0].Results, len(result.Violations()))
+
+	first := log.Runs[0].Results[0]
+	assert.Equal(t, "broken-links", first.RuleID)
+	assert.Equal(t, LevelError, first.Level)
+	assert.Equal(t, "https://shop.test", first.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+
+	var ids []string
+	for _, r := range log.Runs[0].Tool.Driver.Rules {
+		ids = append(ids, r.ID)
+	}
+	assert.ElementsMatch(t, []string{"broken-links", "broken-images", "broken-resources", "missing-title", "h1-count", "crawl"}, ids)
+	assert.Equal(t, "https://shop.test: 1 broken images, at most 0 allowed", log.Runs[0].Results[1].Message.Text)
+}
@@ -0,0 +1,93 @@
+package gate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the checks as JUnit XML. Every analyzed URL is a test
+// suite and every check a test case named after its rule. Violated error
+// rules are failures, URLs that could not be crawled errors and violated
+// warning rules passing test cases with the message as output.
+func WriteJUnit(w io.Writer, result *Result) error {
+	doc := junitTestSuites{Name: "analyze"}
+	suites := map[string]int{}
+
+	for _, c := range result.Checks {
+		i, ok := suites[c.Target]
+		if !ok {
+			i = len(doc.Suites)
+			suites[c.Target] = i
+			doc.Suites = append(doc.Suites, junitTestSuite{Name: c.Target})
+		}
+		suite := &doc.Suites[i]
+
+		tc := junitTestCase{Name: c.Rule, ClassName: c.URL}
+
+		switch {
+		case c.Passed:
+		case c.Rule == crawlRule:
+			tc.Error = &junitProblem{Message: c.Message, Type: string(c.Level), Text: c.Description}
+			suite.Errors++
+		case c.Level == LevelError:
+			tc.Failure = &junitProblem{Message: c.Message, Type: string(c.Level), Text: c.Description}
+			suite.Failures++
+		default:
+			tc.SystemOut = fmt.Sprintf("%s: %s", c.Level, c.Message)
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	for _, suite := range doc.Suites {
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Errors += suite.Errors
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,142 @@
+package gate
+
+import (
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+)
+
+type scope int
+
+const (
+	// scopePage metrics are measured for every crawled page
+	scopePage scope = iota
+	// scopeCrawl metrics are measured once over all pages of a crawl
+	scopeCrawl
+)
+
+// metric measures a property of crawled pages. Rules fail when the measured
+// value is above their max.
+type metric struct {
+	description string
+	unit        string
+	scope       scope
+	max         int
+	measure     func(pages []*crawler.CrawlResult) int
+}
+
+var metrics = map[string]metric{
+	"broken-links": {
+		description: "Broken links of all crawled pages",
+		unit:        "broken links",
+		scope:       scopeCrawl,
+		measure: countLinks(func(lc crawler.LinkCheck) bool {
+			return lc.Status == crawler.LinkStatusBroken
+		}),
+	},
+	"skipped-links": {
+		description: "Links skipped because of robots.txt",
+		unit:        "skipped links",
+		scope:       scopeCrawl,
+		measure: countLinks(func(lc crawler.LinkCheck) bool {
+			return lc.Status == crawler.LinkStatusSkipped
+		}),
+	},
+	"redirect-loops": {
+		description: "Links ending in a redirect loop",
+		unit:        "redirect loops",
+		scope:       scopeCrawl,
+		measure: countLinks(func(lc crawler.LinkCheck) bool {
+			return lc.Redirects.Loop
+		}),
+	},
+	"https-downgrades": {
+		description: "Pages and links redirecting from https to http",
+		unit:        "downgrades",
+		scope:       scopeCrawl,
+		measure: func(pages []*crawler.CrawlResult) int {
+			n := countPages(func(p *crawler.CrawlResult) bool { return p.Redirects.Downgrade })(pages)
+			return n + countLinks(func(lc crawler.LinkCheck) bool { return lc.Redirects.Downgrade })(pages)
+		},
+	},
+	"broken-images": {
+		description: "Images of all crawled pages that could not be loaded",
+		unit:        "broken images",
+		scope:       scopeCrawl,
+		measure:     sumPages(func(p *crawler.CrawlResult) int { return len(p.BrokenImages()) }),
+	},
+	"broken-resources": {
+		description: "Scripts, stylesheets, fonts, frames and media of all crawled pages that could not be loaded",
+		unit:        "broken resources",
+		scope:       scopeCrawl,
+		measure:     sumPages(func(p *crawler.CrawlResult) int { return len(p.BrokenResources()) }),
+	},
+	"missing-title": {
+		description: "Pages have a title",
+		unit:        "missing title",
+		scope:       scopePage,
+		measure:     countPages(func(p *crawler.CrawlResult) bool { return p.Title == "" }),
+	},
+	"missing-h1": {
+		description: "Pages have a h1 heading",
+		unit:        "missing h1 heading",
+		scope:       scopePage,
+		measure:     countPages(func(p *crawler.CrawlResult) bool { return h1s(p) == 0 }),
+	},
+	"h1-count": {
+		description: "Pages have at most one h1 heading",
+		unit:        "h1 headings",
+		scope:       scopePage,
+		max:         1,
+		measure:     sumPages(h1s),
+	},
+	"redirect-hops": {
+		description: "Redirects before the page is reached",
+		unit:        "redirects",
+		scope:       scopePage,
+		max:         3,
+		measure:     sumPages(func(p *crawler.CrawlResult) int { return len(p.Redirects.Hops) }),
+	},
+}
+
+// h1s counts the h1 headings of the outline of a page
+func h1s(p *crawler.CrawlResult) int {
+	n := 0
+	for _, h := range p.Outline.Headings {
+		if h.Level == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func sumPages(count func(p *crawler.CrawlResult) int) func(pages []*crawler.CrawlResult) int {
+	return func(pages []*crawler.CrawlResult) int {
+		n := 0
+		for _, p := range pages {
+			n += count(p)
+		}
+		return n
+	}
+}
+
+func countPages(match func(p *crawler.CrawlResult) bool) func(pages []*crawler.CrawlResult) int {
+	return sumPages(func(p *crawler.CrawlResult) int {
+		if match(p) {
+			return 1
+		}
+		return 0
+	})
+}
+
+func countLinks(match func(lc crawler.LinkCheck) bool) func(pages []*crawler.CrawlResult) int {
+	return func(pages []*crawler.CrawlResult) int {
+		n := 0
+		for _, p := range pages {
+			for _, lc := range p.LinkChecks {
+				if match(lc) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+}
@@ -0,0 +1,207 @@
+package gate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+)
+
+var ErrInvalidConfig = errors.New("invalid gate config")
+
+// Level is the severity of a rule. Only violations of error rules fail the
+// gate.
+type Level string
+
+const (
+	LevelError   Level = "error"
+	LevelWarning Level = "warning"
+)
+
+// crawlRule is the check reported for URLs that could not be crawled
+const crawlRule = "crawl"
+
+// Rule limits a metric of the crawl. Max defaults to the threshold of the
+// metric, Level to error.
+type Rule struct {
+	ID    string `json:"rule"`
+	Max   *int   `json:"max,omitempty"`
+	Level Level  `json:"level,omitempty"`
+}
+
+// Config is the set of rules a crawl has to pass
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// DefaultConfig fails on broken links, images and resources and pages without
+// title, and warns about pages without exactly one h1
+func DefaultConfig() *Config {
+	return &Config{Rules: []Rule{
+		{ID: "broken-links"},
+		{ID: "broken-images"},
+		{ID: "broken-resources"},
+		{ID: "missing-title"},
+		{ID: "missing-h1", Level: LevelWarning},
+		{ID: "h1-count", Level: LevelWarning},
+	}}
+}
+
+// LoadConfig reads a JSON config from r
+func LoadConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// LoadConfigFile reads a JSON config from the file at path
+func LoadConfigFile(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadConfig(file)
+}
+
+// Validate checks that every rule refers to a known metric with a valid
+// threshold and level
+func (c *Config) Validate() error {
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("%w: at least one rule is required", ErrInvalidConfig)
+	}
+
+	for _, r := range c.Rules {
+		if _, ok := metrics[r.ID]; !ok {
+			return fmt.Errorf("%w: unknown rule: %s", ErrInvalidConfig, r.ID)
+		}
+
+		if r.Max != nil && *r.Max < 0 {
+			return fmt.Errorf("%w: max of %s can not be negative", ErrInvalidConfig, r.ID)
+		}
+
+		switch r.Level {
+		case "", LevelError, LevelWarning:
+		default:
+			return fmt.Errorf("%w: unsupported level of %s: %s", ErrInvalidConfig, r.ID, r.Level)
+		}
+	}
+
+	return nil
+}
+
+// Check is the outcome of a rule for a single page or crawl
+type Check struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Level       Level  `json:"level"`
+	Target      string `json:"target"`
+	URL         string `json:"url"`
+	Value       int    `json:"value"`
+	Max         int    `json:"max"`
+	Passed      bool   `json:"passed"`
+	Message     string `json:"message,omitempty"`
+}
+
+// Result holds the checks of all rules in the order of the analyzed URLs
+type Result struct {
+	Checks []Check `json:"checks"`
+}
+
+// Failed reports whether a rule of level error was violated
+func (r *Result) Failed() bool {
+	for _, c := range r.Checks {
+		if !c.Passed && c.Level == LevelError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Violations returns the checks that did not pass
+func (r *Result) Violations() []Check {
+	var checks []Check
+	for _, c := range r.Checks {
+		if !c.Passed {
+			checks = append(checks, c)
+		}
+	}
+
+	return checks
+}
+
+// Evaluate checks every rule against the crawls of the report. Page rules
+// are checked for every crawled page, crawl rules once per analyzed URL. URLs
+// that could not be crawled fail with the "crawl" rule.
+func Evaluate(cfg *Config, report *crawler.BatchReport) *Result {
+	result := &Result{}
+
+	for _, item := range report.Items {
+		if item.Result == nil {
+			result.Checks = append(result.Checks, Check{
+				Rule:        crawlRule,
+				Description: "The URL can be crawled",
+				Level:       LevelError,
+				Target:      item.URL,
+				URL:         item.URL,
+				Message:     item.Error,
+			})
+			continue
+		}
+
+		pages := item.Result.Pages()
+
+		for _, r := range cfg.Rules {
+			m := metrics[r.ID]
+
+			switch m.scope {
+			case scopeCrawl:
+				result.Checks = append(result.Checks, r.check(m, item.URL, item.URL, m.measure(pages)))
+			case scopePage:
+				for _, page := range pages {
+					result.Checks = append(result.Checks, r.check(m, item.URL, page.URL, m.measure([]*crawler.CrawlResult{page})))
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func (r Rule) check(m metric, target, url string, value int) Check {
+	c := Check{
+		Rule:        r.ID,
+		Description: m.description,
+		Level:       r.Level,
+		Target:      target,
+		URL:         url,
+		Value:       value,
+		Max:         m.max,
+	}
+
+	if c.Level == "" {
+		c.Level = LevelError
+	}
+	if r.Max != nil {
+		c.Max = *r.Max
+	}
+
+	c.Passed = c.Value <= c.Max
+	if !c.Passed {
+		c.Message = fmt.Sprintf("%s: %d %s, at most %d allowed", url, c.Value, m.unit, c.Max)
+	}
+
+	return c
+}
@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/util"
+)
+
+// retryAfterSeconds is suggested to clients when the job queue is full
+const retryAfterSeconds = "5"
+
+type jobController struct {
+	m      *Manager
+	logger *slog.Logger
+}
+
+func NewJobController(m *Manager, l *slog.Logger) *jobController {
+	return &jobController{m: m, logger: l}
+}
+
+// SubmitHandler
+// Queues a crawl of the URL of a JSON CrawlRequest and responds with the job
+func (ctrl *jobController) SubmitHandler(w http.ResponseWriter, r *http.Request) {
+	cr, err := crawler.NewCrawlRequestFromJSON(w, r)
+	if err == nil {
+		err = cr.Validate()
+	}
+	if err != nil {
+		ctrl.logger.Warn("Job request validation failed", "error", err.Error())
+		crawler.WriteError(w, err)
+		return
+	}
+
+	job, err := ctrl.m.Submit(cr.URL, cr.CrawlOptions()...)
+	if errors.Is(err, ErrQueueFull) {
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		util.WriteJSON(w, http.StatusServiceUnavailable, crawler.APIError{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	util.WriteJSON(w, http.StatusAccepted, job)
+}
+
+// GetHandler
+// Responds with the status, progress and, once done, the result of a job
+func (ctrl *jobController) GetHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := ctrl.m.Get(r.PathValue("id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusNotFound, crawler.APIError{Error: err.Error()})
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, job)
+}
+
+// Result returns the crawl result of the job named by the request path. Jobs
+// that are not done return ErrNoResult with their status and error.
+func (ctrl *jobController) Result(r *http.Request) (*crawler.CrawlResult, error) {
+	job, err := ctrl.m.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case job.Status == StatusDone:
+		return job.Result, nil
+	case job.Error != "":
+		return nil, fmt.Errorf("%w: %s: %s", ErrNoResult, job.Status, job.Error)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNoResult, job.Status)
+	}
+}
+
+// EventsHandler
+// Streams the crawl events and status changes of a job as Server-Sent Events.
+// Crawl events are named after their type, job snapshots are named "job". The
+// stream ends with the final job snapshot.
+func (ctrl *jobController) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	rc := http.NewResponseController(w)
+
+	updates, unsubscribe, err := ctrl.m.Subscribe(id)
+	if err != nil {
+		util.WriteJSON(w, http.StatusNotFound, crawler.APIError{Error: err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		ctrl.logger.Error("Failed to start event stream", "job_id", id, "error", err.Error())
+		return
+	}
+
+	finished := false
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case u, ok := <-updates:
+			if !ok {
+				// Updates might have been dropped, the final state is sent
+				// unless it already was
+				if !finished {
+					if job, err := ctrl.m.Get(id); err == nil {
+						ctrl.writeEvent(w, "job", job)
+					}
+				}
+				_ = rc.Flush()
+				return
+			}
+
+			if u.Job != nil {
+				finished = u.Job.Finished()
+				ctrl.writeEvent(w, "job", u.Job)
+			} else {
+				ctrl.writeEvent(w, string(u.Event.Type), u.Event)
+			}
+			_ = rc.Flush()
+		}
+	}
+}
+
+// writeEvent writes v as a single Server-Sent Event with the given name
+func (ctrl *jobController) writeEvent(w http.ResponseWriter, name string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		ctrl.logger.Error("Failed to encode event", "event", name, "error", err.Error())
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
+
+// CancelHandler
+// Cancels a queued or running job
+func (ctrl *jobController) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := ctrl.m.Cancel(r.PathValue("id"))
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		util.WriteJSON(w, http.StatusNotFound, crawler.APIError{Error: err.Error()})
+	case errors.Is(err, ErrJobFinished):
+		util.WriteJSON(w, http.StatusConflict, crawler.APIError{Error: err.Error()})
+	default:
+		util.WriteJSON(w, http.StatusOK, job)
+	}
+}
@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+	"github.com/rewebcan/url-fetcher-home24/internal/fetcher"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingCrawler blocks until released or canceled
+type blockingCrawler struct {
+	release chan struct{}
+}
+
+func (b blockingCrawler) Crawl(ctx context.Context, url string, opts ...crawler.CrawlOption) (*crawler.CrawlResult, error) {
+	if url == "https://broken.test" {
+		return nil, errors.New("could not reach to server")
+	}
+
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	r := &crawler.CrawlResult{}
+	r.URL = url
+
+	return r, nil
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, status Status) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		assert.NoError(t, err)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not reach status %s", id, status)
+	return Job{}
+}
+
+func newTestManager(opts ...Option) (*Manager, blockingCrawler) {
+	c := blockingCrawler{release: make(chan struct{})}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return NewManager(c, logger, opts...), c
+}
+
+func TestManager_Done(t *testing.T) {
+	m, c := newTestManager()
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://shop.test")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.NotEmpty(t, job.ID)
+
+	waitForStatus(t, m, job.ID, StatusRunning)
+	close(c.release)
+
+	job = waitForStatus(t, m, job.ID, StatusDone)
+	assert.Equal(t, "https://shop.test", job.Result.URL)
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	_, err = m.Cancel(job.ID)
+	assert.ErrorIs(t, err, ErrJobFinished)
+}
+
+func TestManager_Failed(t *testing.T) {
+	m, _ := newTestManager()
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://broken.test")
+	assert.NoError(t, err)
+
+	job = waitForStatus(t, m, job.ID, StatusFailed)
+	assert.Equal(t, "could not reach to server", job.Error)
+	assert.Nil(t, job.Result)
+}
+
+func TestManager_Backpressure(t *testing.T) {
+	m, c := newTestManager(WithWorkers(1), WithQueueSize(1))
+	defer m.Shutdown()
+	defer close(c.release)
+
+	running, err := m.Submit("https://shop.test/1")
+	assert.NoError(t, err)
+	waitForStatus(t, m, running.ID, StatusRunning)
+
+	queued, err := m.Submit("https://shop.test/2")
+	assert.NoError(t, err)
+
+	_, err = m.Submit("https://shop.test/3")
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// Canceling the queued job frees its slot right away
+	_, err = m.Cancel(queued.ID)
+	assert.NoError(t, err)
+	queued, _ = m.Get(queued.ID)
+	assert.Equal(t, StatusCanceled, queued.Status)
+
+	next, err := m.Submit("https://shop.test/3")
+	assert.NoError(t, err)
+	_, err = m.Cancel(next.ID)
+	assert.NoError(t, err)
+
+	// Canceling the running job stops the crawl
+	_, err = m.Cancel(running.ID)
+	assert.NoError(t, err)
+	waitForStatus(t, m, running.ID, StatusCanceled)
+}
+
+func TestManager_Timeout(t *testing.T) {
+	m, _ := newTestManager(WithJobTimeout(10 * time.Millisecond))
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://shop.test")
+	assert.NoError(t, err)
+
+	job = waitForStatus(t, m, job.ID, StatusFailed)
+	assert.Equal(t, context.DeadlineExceeded.Error(), job.Error)
+}
+
+func TestManager_CancelCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body><a href="/slow">Slow</a></body></html>`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := crawler.NewCrawler(fetcher.NewFetcher(server.Client(), logger, 10<<20), logger)
+	m := NewManager(c, logger)
+	defer m.Shutdown()
+
+	job, err := m.Submit(server.URL)
+	assert.NoError(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Progress.PagesCrawled == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		job, _ = m.Get(job.ID)
+	}
+
+	_, err = m.Cancel(job.ID)
+	assert.NoError(t, err)
+	job = waitForStatus(t, m, job.ID, StatusCanceled)
+	assert.Equal(t, context.Canceled.Error(), job.Error)
+	assert.Nil(t, job.Result)
+	assert.Zero(t, job.Progress.LinksBroken)
+}
+
+func TestManager_NotFound(t *testing.T) {
+	m, _ := newTestManager()
+	defer m.Shutdown()
+
+	_, err := m.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = m.Cancel("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_Subscribe(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{
+		"https://shop.test":          {URL: "https://shop.test", Anchors: []fetcher.Anchor{{URL: "/category"}, {URL: "/missing"}}},
+		"https://shop.test/category": {URL: "https://shop.test/category"},
+	})
+	m := NewManager(crawler.NewCrawler(f, logger), logger)
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://shop.test", crawler.WithCrawlDepth(2))
+	assert.NoError(t, err)
+
+	updates, unsubscribe, err := m.Subscribe(job.ID)
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	var (
+		jobs   []Job
+		events []crawler.EventType
+	)
+	for u := range updates {
+		if u.Job != nil {
+			jobs = append(jobs, *u.Job)
+		} else {
+			events = append(events, u.Event.Type)
+		}
+	}
+
+	assert.Equal(t, StatusDone, jobs[len(jobs)-1].Status)
+	assert.Equal(t, crawler.Progress{PagesCrawled: 2, LinksFound: 2, LinksChecked: 2, LinksBroken: 1}, jobs[len(jobs)-1].Progress)
+	assert.ElementsMatch(t, []crawler.EventType{crawler.EventPageFetched, crawler.EventLinkChecked, crawler.EventLinkBroken, crawler.EventPageFetched}, events)
+
+	// Finished jobs only stream their final state
+	updates, _, err = m.Subscribe(job.ID)
+	assert.NoError(t, err)
+
+	u, ok := <-updates
+	assert.True(t, ok)
+	assert.Equal(t, StatusDone, u.Job.Status)
+	_, ok = <-updates
+	assert.False(t, ok)
+
+	_, _, err = m.Subscribe("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// stallingFetcher blocks the ping of /last until released
+type stallingFetcher struct {
+	fetcher.Fetcher
+	release chan struct{}
+}
+
+func (f stallingFetcher) Ping(ctx context.Context, url string, opts ...fetcher.PingOption) (*fetcher.PingResult, error) {
+	if url == "https://shop.test/last" {
+		<-f.release
+	}
+
+	return f.Fetcher.Ping(ctx, url, opts...)
+}
+
+func TestManager_EventBuffer(t *testing.T) {
+	anchors := []fetcher.Anchor{{URL: "/last"}}
+	for i := 0; i < eventBuffer+100; i++ {
+		anchors = append(anchors, fetcher.Anchor{URL: fmt.Sprintf("/p%d", i)})
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := stallingFetcher{
+		Fetcher: fetcher.NewFakeFetcherFromMap(map[string]*fetcher.FetchResult{"https://shop.test": {URL: "https://shop.test", Anchors: anchors}}),
+		release: make(chan struct{}),
+	}
+	m := NewManager(crawler.NewCrawler(f, logger), logger)
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://shop.test")
+	assert.NoError(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Progress.LinksChecked < eventBuffer+100 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		job, _ = m.Get(job.ID)
+	}
+
+	updates, unsubscribe, err := m.Subscribe(job.ID)
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	// A snapshot of the job and only the latest events
+	assert.Equal(t, 1+eventBuffer, len(updates))
+	close(f.release)
+}
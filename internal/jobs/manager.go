@@ -0,0 +1,436 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rewebcan/url-fetcher-home24/internal/crawler"
+)
+
+var (
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrNotFound    = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+	ErrNoResult    = errors.New("job has no result")
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Job is a snapshot of a crawl job
+type Job struct {
+	ID         string               `json:"id"`
+	URL        string               `json:"url"`
+	Status     Status               `json:"status"`
+	Progress   crawler.Progress     `json:"progress"`
+	Result     *crawler.CrawlResult `json:"result,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	StartedAt  *time.Time           `json:"started_at,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job reached a final state
+func (j Job) Finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// Update is streamed to the subscribers of a job. It either holds an event
+// of the running crawl or a snapshot of the job after its status changed.
+type Update struct {
+	Event *crawler.Event
+	Job   *Job
+}
+
+// subscriberBuffer is the number of updates a subscriber may fall behind
+// before further updates are dropped for it
+const subscriberBuffer = 256
+
+// eventBuffer is the number of the latest events kept for late subscribers
+const eventBuffer = 1000
+
+// job is the mutable state of a job, guarded by the manager mutex
+type job struct {
+	Job
+	opts   []crawler.CrawlOption
+	cancel context.CancelFunc
+
+	// the latest events are kept while the job runs so late subscribers can
+	// catch up
+	events []crawler.Event
+	subs   map[chan Update]struct{}
+}
+
+// Option is a function that configures the manager
+type Option func(*Manager)
+
+// WithWorkers sets the number of crawls run in parallel
+func WithWorkers(n int) Option {
+	return func(m *Manager) {
+		m.workers = n
+	}
+}
+
+// WithQueueSize sets how many jobs may wait for a worker before Submit
+// rejects new jobs
+func WithQueueSize(n int) Option {
+	return func(m *Manager) {
+		m.queueSize = n
+	}
+}
+
+// WithJobTimeout bounds the duration of a single crawl
+func WithJobTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.timeout = d
+	}
+}
+
+// WithRetention sets how long finished jobs can be polled
+func WithRetention(d time.Duration) Option {
+	return func(m *Manager) {
+		m.retention = d
+	}
+}
+
+// Manager runs crawl jobs in the background on a fixed number of workers
+type Manager struct {
+	c      crawler.Crawler
+	logger *slog.Logger
+
+	workers   int
+	queueSize int
+	timeout   time.Duration
+	retention time.Duration
+
+	// wake signals the workers that jobs were queued
+	wake     chan struct{}
+	ctx      context.Context
+	shutdown context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	queue   []*job
+	running int
+}
+
+// NewManager creates a Manager and starts its workers
+func NewManager(c crawler.Crawler, logger *slog.Logger, opts ...Option) *Manager {
+	m := &Manager{
+		c:         c,
+		logger:    logger,
+		workers:   2,
+		queueSize: 20,
+		timeout:   5 * time.Minute,
+		retention: time.Hour,
+		jobs:      make(map[string]*job),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.wake = make(chan struct{}, 1)
+	m.ctx, m.shutdown = context.WithCancel(context.Background())
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+
+	return m
+}
+
+// Submit queues a crawl of url and returns the queued job. ErrQueueFull is
+// returned when all workers are busy and the queue has no room left. Canceled
+// jobs do not take up room in the queue.
+func (m *Manager) Submit(url string, opts ...crawler.CrawlOption) (Job, error) {
+	j := &job{
+		Job:  Job{ID: newID(), URL: url, Status: StatusQueued, CreatedAt: time.Now()},
+		opts: opts,
+		subs: make(map[chan Update]struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.evictExpired()
+
+	if len(m.queue) >= m.queueSize+m.workers-m.running {
+		return Job{}, ErrQueueFull
+	}
+
+	m.queue = append(m.queue, j)
+	m.signal()
+
+	m.jobs[j.ID] = j
+	m.logger.Info("Job queued", "job_id", j.ID, "url", url)
+
+	return j.Job, nil
+}
+
+// Get returns a snapshot of the job with the given id
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	return j.Job, nil
+}
+
+// Subscribe streams the updates of the job with the given id. The channel
+// starts with a snapshot of the job and the latest events of the crawl, and is
+// closed once the job finished. Updates are dropped for subscribers that do
+// not keep up, the final state can always be read with Get.
+func (m *Manager) Subscribe(id string) (<-chan Update, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	ch := make(chan Update, 1+len(j.events)+subscriberBuffer)
+	snapshot := j.Job
+	ch <- Update{Job: &snapshot}
+	for _, e := range j.events {
+		ch <- Update{Event: &e}
+	}
+
+	if j.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	j.subs[ch] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, nil
+}
+
+// Cancel stops a queued or running job
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if j.Finished() {
+		return j.Job, ErrJobFinished
+	}
+
+	if j.cancel != nil {
+		// The worker records the final state once the crawl returned
+		j.cancel()
+	} else {
+		m.dequeue(j)
+		m.finish(j, StatusCanceled, nil, context.Canceled)
+	}
+
+	m.logger.Info("Job canceled", "job_id", id)
+
+	return j.Job, nil
+}
+
+// Shutdown cancels all jobs and waits for the workers to stop
+func (m *Manager) Shutdown() {
+	m.shutdown()
+	m.wg.Wait()
+}
+
+func (m *Manager) work() {
+	defer m.wg.Done()
+
+	for m.ctx.Err() == nil {
+		if j := m.next(); j != nil {
+			m.run(j)
+			m.done()
+			continue
+		}
+
+		select {
+		case <-m.ctx.Done():
+		case <-m.wake:
+		}
+	}
+}
+
+// next takes the oldest queued job, or returns nil when the queue is empty
+func (m *Manager) next() *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) == 0 {
+		return nil
+	}
+
+	j := m.queue[0]
+	m.queue = m.queue[1:]
+	m.running++
+	if len(m.queue) > 0 {
+		// Pass the signal on to the next idle worker
+		m.signal()
+	}
+
+	return j
+}
+
+// done frees the worker slot of a job taken by next
+func (m *Manager) done() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running--
+}
+
+// signal wakes an idle worker without waiting
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue removes a job from the queue, the manager mutex must be held
+func (m *Manager) dequeue(j *job) {
+	for i, queued := range m.queue {
+		if queued == j {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Manager) run(j *job) {
+	ctx, cancel := context.WithTimeout(m.ctx, m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	if j.Finished() {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.Status = StatusRunning
+	j.StartedAt = &now
+	j.cancel = cancel
+	m.publish(j, Update{Job: &j.Job})
+	m.mu.Unlock()
+
+	m.logger.Info("Job started", "job_id", j.ID, "url", j.URL)
+
+	opts := append(append([]crawler.CrawlOption{}, j.opts...), crawler.WithObserver(crawler.ObserverFunc(func(e crawler.Event) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		j.Progress = e.Progress
+		if len(j.events) >= eventBuffer {
+			j.events = j.events[1:]
+		}
+		j.events = append(j.events, e)
+		m.publish(j, Update{Event: &e})
+	})))
+
+	result, err := m.c.Crawl(ctx, j.URL, opts...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The context decides over the result, a crawler may return what it
+	// got until it was canceled
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		m.finish(j, StatusCanceled, nil, ctx.Err())
+	case ctx.Err() != nil:
+		m.finish(j, StatusFailed, nil, ctx.Err())
+	case err == nil:
+		m.finish(j, StatusDone, result, nil)
+	default:
+		m.finish(j, StatusFailed, nil, err)
+	}
+}
+
+// finish records the final state of a job, the manager mutex must be held
+func (m *Manager) finish(j *job, status Status, result *crawler.CrawlResult, err error) {
+	now := time.Now()
+	j.Status = status
+	j.Result = result
+	j.FinishedAt = &now
+	j.cancel = nil
+
+	if err != nil {
+		j.Error = err.Error()
+	}
+
+	m.publish(j, Update{Job: &j.Job})
+	for ch := range j.subs {
+		close(ch)
+	}
+	j.subs = nil
+	j.events = nil
+
+	m.logger.Info("Job finished", "job_id", j.ID, "status", status)
+}
+
+// publish sends an update to the subscribers of a job without waiting for
+// them, the manager mutex must be held
+func (m *Manager) publish(j *job, u Update) {
+	if u.Job != nil {
+		snapshot := *u.Job
+		u.Job = &snapshot
+	}
+
+	for ch := range j.subs {
+		select {
+		case ch <- u:
+		default:
+			m.logger.Warn("Dropped job update for slow subscriber", "job_id", j.ID)
+		}
+	}
+}
+
+// evictExpired removes finished jobs past the retention, the manager mutex
+// must be held
+func (m *Manager) evictExpired() {
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
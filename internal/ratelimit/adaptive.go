@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idleWindowTTL is how long the window of a host without requests is kept
+// before it is dropped and the host starts over at the initial limit
+const idleWindowTTL = 10 * time.Minute
+
+// hostWindow is the concurrency window of a single host
+type hostWindow struct {
+	limit    float64
+	inFlight int
+	waiters  []chan struct{}
+	lastUsed time.Time
+}
+
+func (w *hostWindow) idle() bool {
+	return w.inFlight == 0 && len(w.waiters) == 0
+}
+
+func (w *hostWindow) capacity() int {
+	return int(math.Floor(w.limit))
+}
+
+func (w *hostWindow) remove(ch chan struct{}) bool {
+	for i, waiter := range w.waiters {
+		if waiter == ch {
+			w.waiters = append(w.waiters[:i], w.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// AdaptiveLimiter bounds the number of in-flight requests per host and adapts
+// the bound with AIMD: every healthy response raises the limit additively
+// while slow responses, throttling and server errors halve it.
+type AdaptiveLimiter struct {
+	min           int
+	max           int
+	latencyTarget time.Duration
+
+	mu        sync.Mutex
+	hosts     map[string]*hostWindow
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewAdaptiveLimiter creates an AdaptiveLimiter keeping the per-host limit
+// between min and max. Responses slower than latencyTarget count as a sign of
+// an overloaded server. Hosts start in the middle of the range.
+func NewAdaptiveLimiter(min, max int, latencyTarget time.Duration) *AdaptiveLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+
+	return &AdaptiveLimiter{min: min, max: max, latencyTarget: latencyTarget, hosts: make(map[string]*hostWindow), now: time.Now}
+}
+
+// Acquire blocks until a request to host fits into its window or the context
+// is done
+func (l *AdaptiveLimiter) Acquire(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	w := l.window(host)
+	if len(w.waiters) == 0 && w.inFlight < w.capacity() {
+		w.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+
+	ch := make(chan struct{})
+	w.waiters = append(w.waiters, ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if !w.remove(ch) {
+			// The slot was handed over concurrently, pass it on
+			w.inFlight--
+			l.wake(w)
+		}
+		l.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// Release frees the slot taken by Acquire and adapts the host limit to the
+// observed latency. overloaded reports responses like 429, 5xx or timeouts.
+func (l *AdaptiveLimiter) Release(host string, latency time.Duration, overloaded bool) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := l.window(host)
+	w.inFlight--
+	defer l.sweep()
+
+	if overloaded || (l.latencyTarget > 0 && latency > l.latencyTarget) {
+		w.limit = math.Max(float64(l.min), w.limit/2)
+	} else {
+		w.limit = math.Min(float64(l.max), w.limit+1/w.limit)
+	}
+
+	l.wake(w)
+}
+
+// Cancel frees the slot taken by Acquire without adapting the host limit, for
+// requests that were never sent
+func (l *AdaptiveLimiter) Cancel(host string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := l.window(host)
+	w.inFlight--
+	l.wake(w)
+}
+
+// Limit returns the current concurrency limit of host
+func (l *AdaptiveLimiter) Limit(host string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w, ok := l.hosts[strings.ToLower(host)]; ok {
+		return w.capacity()
+	}
+
+	return int(l.initialLimit())
+}
+
+func (l *AdaptiveLimiter) initialLimit() float64 {
+	return math.Ceil(float64(l.min+l.max) / 2)
+}
+
+func (l *AdaptiveLimiter) window(host string) *hostWindow {
+	host = strings.ToLower(host)
+
+	w, ok := l.hosts[host]
+	if !ok {
+		w = &hostWindow{limit: l.initialLimit()}
+		l.hosts[host] = w
+	}
+	w.lastUsed = l.now()
+
+	return w
+}
+
+// sweep drops the windows of hosts that had no request for idleWindowTTL, at
+// most once per idleWindowTTL
+func (l *AdaptiveLimiter) sweep() {
+	now := l.now()
+	if now.Sub(l.lastSweep) < idleWindowTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for host, w := range l.hosts {
+		if w.idle() && now.Sub(w.lastUsed) >= idleWindowTTL {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// wake hands free slots of the window to waiting callers in FIFO order
+func (l *AdaptiveLimiter) wake(w *hostWindow) {
+	for len(w.waiters) > 0 && w.inFlight < w.capacity() {
+		ch := w.waiters[0]
+		w.waiters = w.waiters[1:]
+		w.inFlight++
+		close(ch)
+	}
+}
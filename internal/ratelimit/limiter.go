@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idleBucketTTL is how long the bucket of a host without requests is kept.
+// Only buckets that refilled completely are dropped, a new bucket of the host
+// starts out the same.
+const idleBucketTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// full reports whether the bucket refilled to burst tokens at now
+func (b *bucket) full(now time.Time, rate float64, burst int) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst)
+}
+
+// HostLimiter is a token bucket rate limiter keyed by host. Every host gets
+// its own bucket that refills at rate tokens per second up to burst tokens.
+type HostLimiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewHostLimiter creates a HostLimiter allowing rate requests per second and
+// bursts of up to burst requests to every host
+func NewHostLimiter(rate float64, burst int) *HostLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &HostLimiter{rate: rate, burst: burst, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Wait blocks until a request to host is allowed or the context is done
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+
+	delay, b := l.reserve(strings.ToLower(host))
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Hand the reserved token back so waiting callers are not delayed by it
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token from the host bucket, possibly going into debt, and
+// returns how long the caller has to wait until the token is available
+func (l *HostLimiter) reserve(host string) (time.Duration, *bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[host] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0, b
+	}
+
+	return time.Duration(-b.tokens / l.rate * float64(time.Second)), b
+}
+
+// sweep drops the full buckets of hosts that had no request for
+// idleBucketTTL, at most once per idleBucketTTL
+func (l *HostLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for host, b := range l.buckets {
+		if now.Sub(b.last) >= idleBucketTTL && b.full(now, l.rate, l.burst) {
+			delete(l.buckets, host)
+		}
+	}
+}
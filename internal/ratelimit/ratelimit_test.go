@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter_Burst(t *testing.T) {
+	l := NewHostLimiter(20, 3)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(ctx, "shop.test"))
+	}
+	assert.Less(t, time.Since(start), 25*time.Millisecond)
+
+	// The fourth request has to wait for a token to be refilled
+	assert.NoError(t, l.Wait(ctx, "shop.test"))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestHostLimiter_PerHost(t *testing.T) {
+	l := NewHostLimiter(1, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	assert.NoError(t, l.Wait(ctx, "shop.test"))
+	assert.NoError(t, l.Wait(ctx, "cdn.test"))
+	assert.NoError(t, l.Wait(ctx, "img.test"))
+	assert.Less(t, time.Since(start), 25*time.Millisecond)
+}
+
+func TestHostLimiter_ContextCanceled(t *testing.T) {
+	l := NewHostLimiter(0.1, 1)
+
+	assert.NoError(t, l.Wait(context.Background(), "shop.test"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, l.Wait(ctx, "shop.test"), context.DeadlineExceeded)
+}
+
+func TestHostLimiter_Disabled(t *testing.T) {
+	var l *HostLimiter
+
+	assert.NoError(t, l.Wait(context.Background(), "shop.test"))
+	assert.NoError(t, NewHostLimiter(0, 0).Wait(context.Background(), "shop.test"))
+}
+
+func TestHostLimiter_EvictsIdleHosts(t *testing.T) {
+	now := time.Now()
+	l := NewHostLimiter(0.002, 2)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.NoError(t, l.Wait(ctx, "shop.test"))
+	assert.NoError(t, l.Wait(ctx, "cdn.test"))
+	assert.NoError(t, l.Wait(ctx, "cdn.test"))
+
+	// After the TTL the refilled bucket is dropped, the bucket still missing
+	// tokens is kept
+	now = now.Add(idleBucketTTL)
+	assert.NoError(t, l.Wait(ctx, "img.test"))
+
+	assert.NotContains(t, l.buckets, "shop.test")
+	assert.Contains(t, l.buckets, "cdn.test")
+	assert.Contains(t, l.buckets, "img.test")
+}
+
+func TestAdaptiveLimiter_AIMD(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 8, time.Second)
+	ctx := context.Background()
+
+	assert.Equal(t, 5, l.Limit("shop.test"))
+
+	// Healthy responses grow the window additively
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, l.Acquire(ctx, "shop.test"))
+		l.Release("shop.test", 10*time.Millisecond, false)
+	}
+	assert.Equal(t, 8, l.Limit("shop.test"))
+
+	// Overload halves it
+	assert.NoError(t, l.Acquire(ctx, "shop.test"))
+	l.Release("shop.test", 10*time.Millisecond, true)
+	assert.Equal(t, 4, l.Limit("shop.test"))
+
+	// Slow responses count as overload and the window never drops below min
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Acquire(ctx, "shop.test"))
+		l.Release("shop.test", 2*time.Second, false)
+	}
+	assert.Equal(t, 1, l.Limit("shop.test"))
+
+	// Other hosts are not affected
+	assert.Equal(t, 5, l.Limit("cdn.test"))
+}
+
+func TestAdaptiveLimiter_Blocks(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 1, 0)
+
+	assert.NoError(t, l.Acquire(context.Background(), "shop.test"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Acquire(ctx, "shop.test"), context.DeadlineExceeded)
+
+	acquired := make(chan struct{})
+	go func() {
+		assert.NoError(t, l.Acquire(context.Background(), "shop.test"))
+		close(acquired)
+	}()
+
+	l.Release("shop.test", time.Millisecond, false)
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting request was not released")
+	}
+}
+
+func TestAdaptiveLimiter_Cancel(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 8, time.Second)
+
+	assert.NoError(t, l.Acquire(context.Background(), "shop.test"))
+	l.Cancel("shop.test")
+	assert.Equal(t, 5, l.Limit("shop.test"))
+
+	// The canceled slot is free again
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Acquire(context.Background(), "shop.test"))
+	}
+}
+
+func TestAdaptiveLimiter_EvictsIdleHosts(t *testing.T) {
+	now := time.Now()
+	l := NewAdaptiveLimiter(1, 8, time.Second)
+	l.now = func() time.Time { return now }
+
+	assert.NoError(t, l.Acquire(context.Background(), "shop.test"))
+	l.Release("shop.test", time.Millisecond, true)
+	assert.NoError(t, l.Acquire(context.Background(), "busy.test"))
+
+	now = now.Add(idleWindowTTL)
+	assert.NoError(t, l.Acquire(context.Background(), "cdn.test"))
+	l.Release("cdn.test", time.Millisecond, false)
+
+	// The idle host is dropped, the host with a request in flight is kept
+	assert.NotContains(t, l.hosts, "shop.test")
+	assert.Contains(t, l.hosts, "busy.test")
+	assert.Equal(t, 5, l.Limit("shop.test"))
+}
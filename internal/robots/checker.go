@@ -0,0 +1,138 @@
+package robots
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// cacheTTL is how long a fetched robots.txt is used before it is fetched again
+const cacheTTL = 24 * time.Hour
+
+// serverErrorTTL is how long a host answering robots.txt with a server error
+// is treated as disallowing everything before robots.txt is fetched again
+const serverErrorTTL = 5 * time.Minute
+
+// Checker provides the robots.txt rules of the host a URL belongs to
+type Checker interface {
+	Rules(ctx context.Context, u *url.URL) *Rules
+}
+
+type cacheEntry struct {
+	ready     chan struct{}
+	rules     *Rules
+	fetchedAt time.Time
+	ttl       time.Duration
+}
+
+type checker struct {
+	httpClient *http.Client
+	userAgent  string
+	logger     *slog.Logger
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+// NewChecker creates a Checker that fetches /robots.txt once per host and
+// caches the rules that apply to the given user-agent
+func NewChecker(httpClient *http.Client, logger *slog.Logger, userAgent string) Checker {
+	return &checker{
+		httpClient: httpClient,
+		userAgent:  userAgent,
+		logger:     logger,
+		cache:      make(map[string]*cacheEntry),
+	}
+}
+
+// Rules
+// Returns the cached rules for the host of u, fetching robots.txt when it is
+// not cached yet. Concurrent callers for the same host share a single fetch.
+func (c *checker) Rules(ctx context.Context, u *url.URL) *Rules {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return AllowAll()
+	}
+
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	if ok && isExpired(entry) {
+		ok = false
+	}
+	if !ok {
+		entry = &cacheEntry{ready: make(chan struct{})}
+		c.cache[key] = entry
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		entry.rules, entry.ttl = c.fetch(ctx, key)
+		entry.fetchedAt = time.Now()
+		close(entry.ready)
+
+		if entry.ttl == 0 {
+			c.mu.Lock()
+			if c.cache[key] == entry {
+				delete(c.cache, key)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.rules
+	case <-ctx.Done():
+		return AllowAll()
+	}
+}
+
+func isExpired(entry *cacheEntry) bool {
+	select {
+	case <-entry.ready:
+		return time.Since(entry.fetchedAt) > entry.ttl
+	default:
+		return false
+	}
+}
+
+// fetch downloads and parses robots.txt and returns how long the rules may
+// be cached. A missing robots.txt allows everything, a server error disallows
+// everything for a short while as RFC 9309 asks. Network errors and canceled
+// requests allow everything without being cached, the requests to the host
+// report their own errors and the next crawl fetches robots.txt again.
+func (c *checker) fetch(ctx context.Context, origin string) (*Rules, time.Duration) {
+	robotsURL := origin + "/robots.txt"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return AllowAll(), 0
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Warn("Failed to fetch robots.txt", "url", robotsURL, "error", err.Error())
+		return AllowAll(), 0
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		c.logger.Warn("robots.txt unavailable, disallowing the host", "url", robotsURL, "status", resp.StatusCode)
+		return DisallowAll(), serverErrorTTL
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		c.logger.Info("No robots.txt available", "url", robotsURL, "status", resp.StatusCode)
+		return AllowAll(), cacheTTL
+	}
+
+	rules := Parse(resp.Body, c.userAgent)
+	c.logger.Info("Fetched robots.txt", "url", robotsURL, "rules", len(rules.rules), "crawl_delay", rules.CrawlDelay)
+
+	return rules, cacheTTL
+}
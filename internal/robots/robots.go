@@ -0,0 +1,179 @@
+package robots
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDisallowed is returned when robots.txt does not allow crawling a URL
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsSizeLimit is the maximum number of bytes parsed from a robots.txt file
+const robotsSizeLimit = 500 << 10
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// Rules holds the robots.txt rules that apply to a single user-agent
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration
+}
+
+// AllowAll returns rules that do not restrict crawling
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// DisallowAll returns rules that do not allow crawling anything but
+// robots.txt itself
+func DisallowAll() *Rules {
+	return &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+}
+
+// Allowed reports whether the given URL may be crawled. The most specific
+// matching rule wins, an allow rule wins over a disallow rule of the same
+// length.
+func (r *Rules) Allowed(u *url.URL) bool {
+	if r == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, matched := true, -1
+	for _, rl := range r.rules {
+		if len(rl.pattern) < matched || !matchPattern(rl.pattern, path) {
+			continue
+		}
+		if len(rl.pattern) > matched || rl.allow {
+			allowed, matched = rl.allow, len(rl.pattern)
+		}
+	}
+
+	return allowed
+}
+
+// Parse reads a robots.txt file and returns the rules of the groups that apply
+// to the given user-agent token, falling back to the "*" groups when no group
+// names the token
+func Parse(r io.Reader, userAgent string) *Rules {
+	token := productToken(userAgent)
+
+	var (
+		matched, wildcard Rules
+		hasMatched        bool
+		agents            []string
+		inRules           bool
+	)
+
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsSizeLimit))
+	for scanner.Scan() {
+		key, value, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		if key == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+
+		inRules = true
+		for _, agent := range agents {
+			switch {
+			case agent == token:
+				hasMatched = true
+				applyLine(&matched, key, value)
+			case agent == "*":
+				applyLine(&wildcard, key, value)
+			}
+		}
+	}
+
+	if hasMatched {
+		return &matched
+	}
+
+	return &wildcard
+}
+
+func applyLine(r *Rules, key, value string) {
+	switch key {
+	case "allow", "disallow":
+		if value == "" {
+			return
+		}
+		r.rules = append(r.rules, rule{allow: key == "allow", pattern: value})
+	case "crawl-delay":
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			r.CrawlDelay = time.Duration(seconds * float64(time.Second))
+		}
+	}
+}
+
+func parseLine(line string) (string, string, bool) {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), true
+}
+
+// productToken reduces a user-agent string like "Bot/1.0 (+info)" to "bot"
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+
+	return strings.ToLower(token)
+}
+
+// matchPattern matches a path against a robots.txt pattern supporting the "*"
+// wildcard and the "$" end anchor
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+
+	return !anchored || rest == ""
+}
@@ -0,0 +1,145 @@
+package robots
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const robotsTxt = `
+# Comments are ignored
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: AuditBot
+User-agent: OtherBot
+Disallow: /checkout
+Crawl-delay: 1.5
+`
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestParse_Wildcard(t *testing.T) {
+	r := Parse(strings.NewReader(robotsTxt), "SomeBot/2.0")
+
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/")))
+	assert.False(t, r.Allowed(mustParseURL(t, "https://shop.test/private/orders")))
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/private/public/page")))
+	assert.False(t, r.Allowed(mustParseURL(t, "https://shop.test/files/catalog.pdf")))
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/files/catalog.pdf?download=1")))
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/robots.txt")))
+	assert.True(t, r.Allowed(mustParseURL(t, "mailto:info@shop.test")))
+	assert.Zero(t, r.CrawlDelay)
+}
+
+func TestParse_UserAgentGroup(t *testing.T) {
+	r := Parse(strings.NewReader(robotsTxt), "AuditBot/1.0 (+https://example.com)")
+
+	// The named group replaces the wildcard group
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/private")))
+	assert.False(t, r.Allowed(mustParseURL(t, "https://shop.test/checkout/step-1")))
+	assert.Equal(t, 1500*time.Millisecond, r.CrawlDelay)
+}
+
+func TestParse_LongestMatchWins(t *testing.T) {
+	r := Parse(strings.NewReader("User-agent: *\nAllow: /p\nDisallow: /p\nDisallow: /products/\nAllow: /products/*/reviews"), "bot")
+
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/page")))
+	assert.False(t, r.Allowed(mustParseURL(t, "https://shop.test/products/sofa")))
+	assert.True(t, r.Allowed(mustParseURL(t, "https://shop.test/products/sofa/reviews")))
+}
+
+func TestChecker(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/robots.txt", r.URL.Path)
+		assert.Equal(t, "AuditBot/1.0", r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte(robotsTxt))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewChecker(server.Client(), logger, "AuditBot/1.0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	checkout := mustParseURL(t, server.URL+"/checkout")
+	assert.False(t, c.Rules(ctx, checkout).Allowed(checkout))
+
+	home := mustParseURL(t, server.URL+"/")
+	assert.True(t, c.Rules(ctx, home).Allowed(home))
+
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestChecker_MissingRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewChecker(server.Client(), logger, "AuditBot/1.0")
+
+	u := mustParseURL(t, server.URL+"/checkout")
+	assert.True(t, c.Rules(context.Background(), u).Allowed(u))
+}
+
+func TestChecker_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewChecker(server.Client(), logger, "AuditBot/1.0")
+
+	u := mustParseURL(t, server.URL+"/")
+	assert.False(t, c.Rules(context.Background(), u).Allowed(u))
+	assert.True(t, DisallowAll().Allowed(mustParseURL(t, server.URL+"/robots.txt")))
+}
+
+func TestChecker_CanceledFetchIsNotCached(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(robotsTxt))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewChecker(server.Client(), logger, "AuditBot/1.0")
+
+	checkout := mustParseURL(t, server.URL+"/checkout")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, c.Rules(canceled, checkout).Allowed(checkout))
+
+	// The next crawl fetches robots.txt instead of allowing everything
+	assert.False(t, c.Rules(context.Background(), checkout).Allowed(checkout))
+	assert.False(t, c.Rules(context.Background(), checkout).Allowed(checkout))
+	assert.Equal(t, int32(1), requests.Load())
+}
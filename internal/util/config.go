@@ -1,8 +1,10 @@
 package util
 
 import (
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +14,33 @@ type CrawlerConfig struct {
 	BodySizeLimit    int64
 	ConcurrencyLimit int
 	CrawlDepth       int
+	UserAgent        string
+	RespectRobots    bool
+	HostRateLimit    float64
+	HostRateBurst    int
+
+	AdaptiveConcurrency bool
+	MinHostConcurrency  int
+	LatencyTarget       time.Duration
+
+	MaxRedirects      int
+	LongRedirectChain int
+	PingStrategy      string
+
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryJitter      float64
+	RetryStatusCodes []int
+
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	JobWorkers   int
+	JobQueueSize int
+	JobTimeout   time.Duration
+
+	BatchWorkers int
 }
 
 // NewDefaultCrawlerConfig creates a default configuration
@@ -21,6 +50,48 @@ func NewDefaultCrawlerConfig() *CrawlerConfig {
 		BodySizeLimit:    10 << 20, // 10MB
 		ConcurrencyLimit: 10,
 		CrawlDepth:       1,
+		UserAgent:        "Home24BasicAnalyzer/1.0",
+		RespectRobots:    true,
+		// A crawl requests at most HostRateLimit times the crawl timeout
+		// (30s for the web UI and API) URLs of a single host, 20 rps leaves
+		// room for pages with several hundred same-host links and images
+		HostRateLimit: 20,
+		HostRateBurst: 10,
+
+		AdaptiveConcurrency: false,
+		MinHostConcurrency:  1,
+		LatencyTarget:       2 * time.Second,
+
+		MaxRedirects:      10,
+		LongRedirectChain: 3,
+		PingStrategy:      "head",
+
+		RetryMaxAttempts: 3,
+		RetryBaseDelay:   200 * time.Millisecond,
+		RetryMaxDelay:    2 * time.Second,
+		RetryJitter:      0.2,
+		RetryStatusCodes: DefaultRetryStatusCodes(),
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+
+		JobWorkers:   2,
+		JobQueueSize: 20,
+		JobTimeout:   5 * time.Minute,
+
+		BatchWorkers: 4,
+	}
+}
+
+// DefaultRetryStatusCodes returns the status codes retried by default: request
+// timeouts, throttling and gateway errors
+func DefaultRetryStatusCodes() []int {
+	return []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
 	}
 }
 
@@ -46,5 +117,141 @@ func LoadCrawlerConfigFromEnv() *CrawlerConfig {
 		}
 	}
 
+	if depthStr := os.Getenv("CRAWLER_CRAWL_DEPTH"); depthStr != "" {
+		if depth, err := strconv.Atoi(depthStr); err == nil && depth > 0 {
+			config.CrawlDepth = depth
+		}
+	}
+
+	if userAgent := os.Getenv("CRAWLER_USER_AGENT"); userAgent != "" {
+		config.UserAgent = userAgent
+	}
+
+	if respectStr := os.Getenv("CRAWLER_RESPECT_ROBOTS"); respectStr != "" {
+		if respect, err := strconv.ParseBool(respectStr); err == nil {
+			config.RespectRobots = respect
+		}
+	}
+
+	if rateStr := os.Getenv("CRAWLER_HOST_RATE_LIMIT"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate >= 0 {
+			config.HostRateLimit = rate
+		}
+	}
+
+	if burstStr := os.Getenv("CRAWLER_HOST_RATE_BURST"); burstStr != "" {
+		if burst, err := strconv.Atoi(burstStr); err == nil && burst > 0 {
+			config.HostRateBurst = burst
+		}
+	}
+
+	if adaptiveStr := os.Getenv("CRAWLER_ADAPTIVE_CONCURRENCY"); adaptiveStr != "" {
+		if adaptive, err := strconv.ParseBool(adaptiveStr); err == nil {
+			config.AdaptiveConcurrency = adaptive
+		}
+	}
+
+	if minStr := os.Getenv("CRAWLER_MIN_HOST_CONCURRENCY"); minStr != "" {
+		if minConcurrency, err := strconv.Atoi(minStr); err == nil && minConcurrency > 0 {
+			config.MinHostConcurrency = minConcurrency
+		}
+	}
+
+	if latencyStr := os.Getenv("CRAWLER_LATENCY_TARGET"); latencyStr != "" {
+		if latency, err := time.ParseDuration(latencyStr); err == nil {
+			config.LatencyTarget = latency
+		}
+	}
+
+	if maxRedirectsStr := os.Getenv("CRAWLER_MAX_REDIRECTS"); maxRedirectsStr != "" {
+		if maxRedirects, err := strconv.Atoi(maxRedirectsStr); err == nil && maxRedirects >= 0 {
+			config.MaxRedirects = maxRedirects
+		}
+	}
+
+	if longChainStr := os.Getenv("CRAWLER_LONG_REDIRECT_CHAIN"); longChainStr != "" {
+		if longChain, err := strconv.Atoi(longChainStr); err == nil && longChain >= 0 {
+			config.LongRedirectChain = longChain
+		}
+	}
+
+	if strategy := os.Getenv("CRAWLER_PING_STRATEGY"); strategy == "head" || strategy == "get" {
+		config.PingStrategy = strategy
+	}
+
+	loadRetryConfigFromEnv(config)
+
+	if thresholdStr := os.Getenv("CRAWLER_BREAKER_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold >= 0 {
+			config.BreakerThreshold = threshold
+		}
+	}
+
+	if cooldownStr := os.Getenv("CRAWLER_BREAKER_COOLDOWN"); cooldownStr != "" {
+		if cooldown, err := time.ParseDuration(cooldownStr); err == nil {
+			config.BreakerCooldown = cooldown
+		}
+	}
+
+	if workersStr := os.Getenv("CRAWLER_JOB_WORKERS"); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil && workers > 0 {
+			config.JobWorkers = workers
+		}
+	}
+
+	if queueSizeStr := os.Getenv("CRAWLER_JOB_QUEUE_SIZE"); queueSizeStr != "" {
+		if queueSize, err := strconv.Atoi(queueSizeStr); err == nil && queueSize >= 0 {
+			config.JobQueueSize = queueSize
+		}
+	}
+
+	if jobTimeoutStr := os.Getenv("CRAWLER_JOB_TIMEOUT"); jobTimeoutStr != "" {
+		if jobTimeout, err := time.ParseDuration(jobTimeoutStr); err == nil {
+			config.JobTimeout = jobTimeout
+		}
+	}
+
+	if batchWorkersStr := os.Getenv("CRAWLER_BATCH_WORKERS"); batchWorkersStr != "" {
+		if batchWorkers, err := strconv.Atoi(batchWorkersStr); err == nil && batchWorkers > 0 {
+			config.BatchWorkers = batchWorkers
+		}
+	}
+
 	return config
 }
+
+func loadRetryConfigFromEnv(config *CrawlerConfig) {
+	if attemptsStr := os.Getenv("CRAWLER_RETRY_MAX_ATTEMPTS"); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil && attempts > 0 {
+			config.RetryMaxAttempts = attempts
+		}
+	}
+
+	if baseDelayStr := os.Getenv("CRAWLER_RETRY_BASE_DELAY"); baseDelayStr != "" {
+		if baseDelay, err := time.ParseDuration(baseDelayStr); err == nil {
+			config.RetryBaseDelay = baseDelay
+		}
+	}
+
+	if maxDelayStr := os.Getenv("CRAWLER_RETRY_MAX_DELAY"); maxDelayStr != "" {
+		if maxDelay, err := time.ParseDuration(maxDelayStr); err == nil {
+			config.RetryMaxDelay = maxDelay
+		}
+	}
+
+	if jitterStr := os.Getenv("CRAWLER_RETRY_JITTER"); jitterStr != "" {
+		if jitter, err := strconv.ParseFloat(jitterStr, 64); err == nil && jitter >= 0 && jitter <= 1 {
+			config.RetryJitter = jitter
+		}
+	}
+
+	if codesStr := os.Getenv("CRAWLER_RETRY_STATUS_CODES"); codesStr != "" {
+		var codes []int
+		for _, codeStr := range strings.Split(codesStr, ",") {
+			if code, err := strconv.Atoi(strings.TrimSpace(codeStr)); err == nil {
+				codes = append(codes, code)
+			}
+		}
+		config.RetryStatusCodes = codes
+	}
+}
@@ -54,6 +54,11 @@ func (r *statusRecorder) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap lets http.ResponseController reach the flusher of the wrapped writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // LoggingMiddleware creates a middleware that logs HTTP requests
 func LoggingMiddleware() Middleware {
 	return func(next http.Handler) http.Handler {